@@ -0,0 +1,76 @@
+package negotools
+
+// API keys of the form "<prefix>_<random>_<checksum>" that can be
+// recognized by secret scanners and checked for typos without a lookup
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// DefaultAPIKeyPrefix is the prefix of API keys if no other is used.
+const DefaultAPIKeyPrefix string = "nego"
+
+// APIKeyRandomLength is the number of random base62 characters of an API
+// key, about 190 bits of entropy.
+const APIKeyRandomLength int = 32
+
+const base62Chars string = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var apiKeyPrefixPattern *regexp.Regexp = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// APIKeyPattern finds API keys with DefaultAPIKeyPrefix in text, e.g. for
+// secret scanning. Matches still have to be checked with VerifyAPIKey.
+var APIKeyPattern *regexp.Regexp = APIKeyRegexp(DefaultAPIKeyPrefix)
+
+// APIKeyRegexp returns a pattern that finds API keys with the given prefix
+// in text.
+func APIKeyRegexp(prefix string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`\b%s_[0-9A-Za-z]{%d}_[0-9A-F]{8}\b`, regexp.QuoteMeta(prefix), APIKeyRandomLength))
+}
+
+// GenerateAPIKey creates a key "<prefix>_<random>_<checksum>". The prefix
+// consists of lowercase letters and digits, the random part is base62 from
+// crypto/rand and the checksum is the CRC32Checksum of everything before
+// it, zero-padded to eight characters.
+func GenerateAPIKey(prefix string) (string, error) {
+	if !apiKeyPrefixPattern.MatchString(prefix) {
+		return "", fmt.Errorf("invalid API key prefix %q: must consist of lowercase letters and digits and start with a letter", prefix)
+	}
+	var random []byte = make([]byte, APIKeyRandomLength)
+	for i := range random {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(base62Chars))))
+		if err != nil {
+			return "", err
+		}
+		random[i] = base62Chars[j.Int64()]
+	}
+	var body string = prefix + "_" + string(random)
+	return body + "_" + apiKeyChecksum(body), nil
+}
+
+// VerifyAPIKey checks the format and the checksum of an API key with the
+// given prefix. It detects typos before the key is looked up; whether the
+// key was ever issued is up to the caller.
+func VerifyAPIKey(key, prefix string) error {
+	if !strings.HasPrefix(key, prefix+"_") {
+		return fmt.Errorf("API key does not start with %q", prefix+"_")
+	}
+	var match []int = APIKeyRegexp(prefix).FindStringIndex(key)
+	if match == nil || match[0] != 0 || match[1] != len(key) {
+		return fmt.Errorf("API key does not have the format %s_<%d base62 characters>_<checksum>", prefix, APIKeyRandomLength)
+	}
+	var separator int = strings.LastIndex(key, "_")
+	if apiKeyChecksum(key[:separator]) != key[separator+1:] {
+		return fmt.Errorf("API key has an invalid checksum")
+	}
+	return nil
+}
+
+func apiKeyChecksum(body string) string {
+	var checksum string = CRC32Checksum(body)
+	return strings.Repeat("0", 8-len(checksum)) + checksum
+}
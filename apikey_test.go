@@ -0,0 +1,58 @@
+package negotools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, err := GenerateAPIKey(DefaultAPIKeyPrefix)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^nego_[0-9A-Za-z]{32}_[0-9A-F]{8}$`, key)
+	assert.NoError(t, VerifyAPIKey(key, DefaultAPIKeyPrefix))
+	assert.Error(t, VerifyAPIKey(key, "spam"))
+
+	other, err := GenerateAPIKey("spam2")
+	assert.NoError(t, err)
+	assert.NotEqual(t, key[5:37], other[6:38])
+	assert.NoError(t, VerifyAPIKey(other, "spam2"))
+
+	for _, prefix := range []string{"", "Spam", "spam_eggs", "2spam"} {
+		_, err = GenerateAPIKey(prefix)
+		assert.Error(t, err, prefix)
+	}
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	var key string = "nego_" + strings.Repeat("a", 32) + "_" + apiKeyChecksum("nego_"+strings.Repeat("a", 32))
+	assert.NoError(t, VerifyAPIKey(key, "nego"))
+	// the CRC32 of "" is 0, checksums are always padded to eight characters
+	assert.Equal(t, "00000000", apiKeyChecksum(""))
+
+	// a single mistyped character is always detected by CRC32
+	for i := 5; i < 37; i++ {
+		mistyped := []byte(key)
+		mistyped[i] = 'b'
+		assert.EqualError(t, VerifyAPIKey(string(mistyped), "nego"), "API key has an invalid checksum")
+	}
+	swapped := []byte(key)
+	swapped[10], swapped[40] = swapped[40], swapped[10]
+	assert.Error(t, VerifyAPIKey(string(swapped), "nego"))
+
+	assert.EqualError(t, VerifyAPIKey("nego_spam", "nego"), "API key does not have the format nego_<32 base62 characters>_<checksum>")
+	assert.Error(t, VerifyAPIKey(key+"x", "nego"))
+	assert.EqualError(t, VerifyAPIKey(key, "ham"), `API key does not start with "ham_"`)
+}
+
+func TestAPIKeyPattern(t *testing.T) {
+	key, err := GenerateAPIKey(DefaultAPIKeyPrefix)
+	assert.NoError(t, err)
+	other, err := GenerateAPIKey("spam")
+	assert.NoError(t, err)
+	text := "export TOKEN=" + key + "\nurl: https://example.com/?key=" + other + "&x=nego_short_00000000"
+
+	assert.Equal(t, []string{key}, APIKeyPattern.FindAllString(text, -1))
+	assert.Equal(t, []string{other}, APIKeyRegexp("spam").FindAllString(text, -1))
+}
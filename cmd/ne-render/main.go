@@ -0,0 +1,282 @@
+// ne-render renders the manifests of an app description file (see
+// negotools.AppConfigFile) as multi-document YAML.
+//
+//	ne-render [-validate] [-diff DIR] APP_FILE [OVERLAY_FILE...]
+//
+// Overlay files (e.g. per environment) override the app file, see
+// negotools.LoadConfigFiles. With -validate the input and the generated
+// objects are checked instead of printed, with -diff the generated objects
+// are compared with the manifests previously rendered into DIR (without
+// printing the values of Secret data). The exit code is 1 if validation
+// fails or differences are found and 2 on other errors.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	negotools "github.com/deepshore/ne-go-tools"
+	"github.com/deepshore/ne-go-tools/lint"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	exitOK     int = 0
+	exitFailed int = 1
+	exitError  int = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ne-render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	validate := flags.Bool("validate", false, "validate the app description and the generated objects instead of printing them")
+	diffDir := flags.String("diff", "", "compare the generated objects with the manifests in `DIR` instead of printing them")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: ne-render [-validate] [-diff DIR] APP_FILE [OVERLAY_FILE...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	config, err := negotools.LoadAppConfig(flags.Args()...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	objects, err := render(config)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	var exitCode int = exitOK
+	if *validate {
+		if !validateApp(config, objects, stdout) {
+			exitCode = exitFailed
+		}
+	}
+	if *diffDir != "" {
+		previous, err := readManifestDir(*diffDir)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		different, err := diffManifests(objects, previous, stdout)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		if different {
+			exitCode = exitFailed
+		}
+	}
+	if !*validate && *diffDir == "" {
+		if err := negotools.EncodeManifests(stdout, objects); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	return exitCode
+}
+
+// generates the app's objects; they are encoded and decoded once so that
+// they carry apiVersion and kind exactly like the printed manifests
+func render(config negotools.AppConfig) ([]runtime.Object, error) {
+	var buffer bytes.Buffer
+	if err := negotools.EncodeManifests(&buffer, negotools.GenerateApp(config).Objects()); err != nil {
+		return nil, err
+	}
+	return negotools.DecodeManifests(&buffer)
+}
+
+func validateApp(config negotools.AppConfig, objects []runtime.Object, stdout io.Writer) bool {
+	var valid bool = true
+	for _, err := range []error{config.Validate(), negotools.GenerateApp(config).CheckReferences()} {
+		if err == nil {
+			continue
+		}
+		valid = false
+		if aggregate, ok := err.(utilerrors.Aggregate); ok {
+			for _, e := range aggregate.Errors() {
+				fmt.Fprintf(stdout, "error: %v\n", e)
+			}
+		} else {
+			fmt.Fprintf(stdout, "error: %v\n", err)
+		}
+	}
+	var findings []lint.Finding = lint.Lint(objects, lint.DefaultConfig())
+	for _, finding := range findings {
+		fmt.Fprintln(stdout, finding.String())
+	}
+	return valid && !lint.HasErrors(findings)
+}
+
+// reads all YAML and JSON files of a directory (not recursively)
+func readManifestDir(dir string) ([]runtime.Object, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var objects []runtime.Object = []runtime.Object{}
+	for _, entry := range entries {
+		var extension string = strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (extension != ".yaml" && extension != ".yml" && extension != ".json") {
+			continue
+		}
+		file, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := negotools.DecodeManifests(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+func objectKey(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return negotools.ObjectKey{
+		GroupVersionKind: obj.GetObjectKind().GroupVersionKind(),
+		Namespace:        accessor.GetNamespace(),
+		Name:             accessor.GetName(),
+	}.String(), nil
+}
+
+func indexObjects(objects []runtime.Object) (map[string]runtime.Object, []string, error) {
+	var index map[string]runtime.Object = map[string]runtime.Object{}
+	var keys []string = []string{}
+	for _, obj := range objects {
+		key, err := objectKey(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := index[key]; ok {
+			return nil, nil, fmt.Errorf("duplicate object %s", key)
+		}
+		index[key] = obj
+		keys = append(keys, key)
+	}
+	return index, keys, nil
+}
+
+// prints added (+), removed (-) and changed (~) objects and reports whether
+// there were any
+func diffManifests(rendered, previous []runtime.Object, stdout io.Writer) (bool, error) {
+	renderedIndex, renderedKeys, err := indexObjects(rendered)
+	if err != nil {
+		return false, err
+	}
+	previousIndex, previousKeys, err := indexObjects(previous)
+	if err != nil {
+		return false, err
+	}
+
+	var different bool = false
+	for _, key := range renderedKeys {
+		old, ok := previousIndex[key]
+		if !ok {
+			different = true
+			fmt.Fprintf(stdout, "+ %s\n", key)
+			continue
+		}
+		changes, err := objectChanges(old, renderedIndex[key])
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", key, err)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		different = true
+		fmt.Fprintf(stdout, "~ %s\n", key)
+		for _, change := range changes {
+			fmt.Fprintf(stdout, "    %s\n", change)
+		}
+	}
+	sort.Strings(previousKeys)
+	for _, key := range previousKeys {
+		if _, ok := renderedIndex[key]; !ok {
+			different = true
+			fmt.Fprintf(stdout, "- %s\n", key)
+		}
+	}
+	return different, nil
+}
+
+// SemanticDiff only reports fields of the desired object, so both objects
+// are defaulted and compared in both directions
+func objectChanges(old, new runtime.Object) ([]string, error) {
+	old, new = old.DeepCopyObject(), new.DeepCopyObject()
+	negotools.SetDefaults(old)
+	negotools.SetDefaults(new)
+	var changes map[string]string = map[string]string{}
+	added, err := negotools.SemanticDiff(new, old)
+	if err != nil {
+		return nil, err
+	}
+	var secret bool = isSecret(new)
+	for _, difference := range added {
+		changes[difference.Path] = formatChange(secret, difference.Path, difference.Live, difference.Desired)
+	}
+	removed, err := negotools.SemanticDiff(old, new)
+	if err != nil {
+		return nil, err
+	}
+	for _, difference := range removed {
+		if _, ok := changes[difference.Path]; !ok {
+			changes[difference.Path] = formatChange(secret, difference.Path, difference.Desired, difference.Live)
+		}
+	}
+
+	var lines []string = make([]string, 0, len(changes))
+	for _, line := range changes {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+func isSecret(obj runtime.Object) bool {
+	var gvk schema.GroupVersionKind = obj.GetObjectKind().GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+// the values of Secrets are not printed, the diff ends up in CI logs
+func formatChange(secret bool, path string, from, to interface{}) string {
+	for _, dataField := range []string{"data", "stringData"} {
+		if secret && (path == dataField || strings.HasPrefix(path, dataField+".")) {
+			switch {
+			case from == nil:
+				return path + ": (added)"
+			case to == nil:
+				return path + ": (removed)"
+			}
+			return path + ": (changed)"
+		}
+	}
+	return fmt.Sprintf("%s: %s -> %s", path, negotools.FormatDiffValue(from), negotools.FormatDiffValue(to))
+}
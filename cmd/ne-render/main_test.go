@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testApp string = `
+name: spam
+namespace: eggs
+config:
+  LOG_LEVEL: info
+deployment:
+  image: registry.example.com/spam:1.2.3
+  containerPort: 8080
+  requests: {cpu: 250m, memory: 128Mi}
+  limits: {cpu: "1", memory: 512Mi}
+  livenessProbe: {path: /healthz}
+  readinessProbe: {path: /ready}
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	app := writeFile(t, dir, "app.yaml", testApp)
+	prod := writeFile(t, dir, "prod.yaml", "deployment:\n  replicas: 3\n")
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitOK, run([]string{app, prod}, &stdout, &stderr))
+	assert.Empty(t, stderr.String())
+	assert.Equal(t, 2, strings.Count(stdout.String(), "---\n"))
+	assert.Contains(t, stdout.String(), "kind: ConfigMap")
+	assert.Contains(t, stdout.String(), "replicas: 3")
+}
+
+func TestRenderValidate(t *testing.T) {
+	dir := t.TempDir()
+	app := writeFile(t, dir, "app.yaml", testApp)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitOK, run([]string{"-validate", app}, &stdout, &stderr))
+	assert.Empty(t, stdout.String())
+
+	latest := writeFile(t, dir, "latest.yaml", "deployment:\n  image: spam\n")
+	stdout.Reset()
+	assert.Equal(t, exitFailed, run([]string{"-validate", app, latest}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "image-tag")
+}
+
+func TestRenderDiff(t *testing.T) {
+	dir := t.TempDir()
+	app := writeFile(t, dir, "app.yaml", testApp)
+	rendered := filepath.Join(dir, "rendered")
+	assert.NoError(t, os.Mkdir(rendered, 0o700))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitOK, run([]string{app}, &stdout, &stderr))
+	writeFile(t, rendered, "manifests.yaml", stdout.String())
+	writeFile(t, rendered, "legacy.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: legacy\n  namespace: eggs\n")
+
+	stdout.Reset()
+	assert.Equal(t, exitFailed, run([]string{"-diff", rendered, app}, &stdout, &stderr))
+	assert.Equal(t, "- ConfigMap/eggs/legacy\n", stdout.String())
+
+	prod := writeFile(t, dir, "prod.yaml", "deployment:\n  replicas: 3\n  readinessProbe: null\n")
+	stdout.Reset()
+	assert.Equal(t, exitFailed, run([]string{"-diff", rendered, app, prod}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "~ Deployment/eggs/spam\n    spec.replicas: 1 -> 3\n")
+	assert.Contains(t, stdout.String(), "spec.template.spec.containers[0].readinessProbe.httpGet.path: \"/ready\" -> <unset>")
+}
+
+func TestRenderDiffSecret(t *testing.T) {
+	dir := t.TempDir()
+	app := writeFile(t, dir, "app.yaml", testApp+"secrets:\n  DB_PASSWORD: s3cr3t-ham\n  OLD_TOKEN: old-eggs\n")
+	rendered := filepath.Join(dir, "rendered")
+	assert.NoError(t, os.Mkdir(rendered, 0o700))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitOK, run([]string{app}, &stdout, &stderr))
+	writeFile(t, rendered, "manifests.yaml", stdout.String())
+
+	rotated := writeFile(t, dir, "rotated.yaml", "secrets:\n  DB_PASSWORD: n3w-spam\n  OLD_TOKEN: null\n  API_KEY: new-key\n")
+	stdout.Reset()
+	assert.Equal(t, exitFailed, run([]string{"-diff", rendered, app, rotated}, &stdout, &stderr))
+	assert.Equal(t, "~ Secret/eggs/spam-secret\n"+
+		"    data.API_KEY: (added)\n    data.DB_PASSWORD: (changed)\n    data.OLD_TOKEN: (removed)\n", stdout.String())
+	for _, value := range []string{"s3cr3t-ham", "old-eggs", "n3w-spam", "new-key"} {
+		assert.NotContains(t, stdout.String(), value)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	dir := t.TempDir()
+	app := writeFile(t, dir, "app.yaml", testApp+"unknown: true\n")
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitError, run([]string{}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage")
+
+	stderr.Reset()
+	assert.Equal(t, exitError, run([]string{app}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "field unknown not found")
+}
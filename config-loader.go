@@ -0,0 +1,417 @@
+package negotools
+
+// loading of generator inputs from layered YAML/JSON files
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kjson "sigs.k8s.io/json"
+)
+
+// ConfigQuantity is a resource quantity written like in Kubernetes manifests,
+// e.g. "250m" or "512Mi".
+type ConfigQuantity struct {
+	resource.Quantity
+}
+
+func (q *ConfigQuantity) UnmarshalYAML(node *yaml.Node) error {
+	quantity, err := resource.ParseQuantity(node.Value)
+	if err != nil || node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: invalid quantity %q", node.Line, node.Value)
+	}
+	q.Quantity = quantity
+	return nil
+}
+
+func (q ConfigQuantity) MarshalYAML() (interface{}, error) {
+	return q.String(), nil
+}
+
+// ConfigDuration is a duration in whole seconds, written as Go duration
+// ("30s", "2m") or as plain number of seconds.
+type ConfigDuration struct {
+	time.Duration
+}
+
+func (d *ConfigDuration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: invalid duration", node.Line)
+	}
+	if seconds, err := strconv.ParseInt(node.Value, 10, 32); err == nil {
+		d.Duration = time.Duration(seconds) * time.Second
+		return nil
+	}
+	duration, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, node.Value)
+	}
+	if duration%time.Second != 0 {
+		return fmt.Errorf("line %d: duration %q must be a whole number of seconds", node.Line, node.Value)
+	}
+	d.Duration = duration
+	return nil
+}
+
+func (d ConfigDuration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d ConfigDuration) seconds() int32 {
+	return int32(d.Duration / time.Second)
+}
+
+// ConfigVolumes are pod volumes written like in Kubernetes manifests.
+type ConfigVolumes []corev1.Volume
+
+func (v *ConfigVolumes) UnmarshalYAML(node *yaml.Node) error {
+	return decodeKubernetesYAML(node, (*[]corev1.Volume)(v))
+}
+
+// ConfigVolumeMounts are container volume mounts written like in Kubernetes
+// manifests.
+type ConfigVolumeMounts []corev1.VolumeMount
+
+func (v *ConfigVolumeMounts) UnmarshalYAML(node *yaml.Node) error {
+	return decodeKubernetesYAML(node, (*[]corev1.VolumeMount)(v))
+}
+
+// Kubernetes types only carry json tags, so the node is converted to JSON
+// and decoded strictly like the API server would do it
+func decodeKubernetesYAML(node *yaml.Node, target interface{}) error {
+	var content interface{}
+	if err := node.Decode(&content); err != nil {
+		return err
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	strictErrs, err := kjson.UnmarshalStrict(data, target)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	if len(strictErrs) > 0 {
+		return fmt.Errorf("line %d: %w", node.Line, errors.Join(strictErrs...))
+	}
+	return nil
+}
+
+type ProbeConfigFile struct {
+	Path             string         `yaml:"path"`
+	Port             int32          `yaml:"port"`
+	InitialDelay     ConfigDuration `yaml:"initialDelay"`
+	Timeout          ConfigDuration `yaml:"timeout"`
+	Period           ConfigDuration `yaml:"period"`
+	FailureThreshold int32          `yaml:"failureThreshold"`
+	SuccessThreshold int32          `yaml:"successThreshold"`
+}
+
+type ResourcesConfigFile struct {
+	Cpu    ConfigQuantity `yaml:"cpu"`
+	Memory ConfigQuantity `yaml:"memory"`
+}
+
+type ImageRewriteRuleConfigFile struct {
+	Prefix      string `yaml:"prefix"`
+	Replacement string `yaml:"replacement"`
+}
+
+// DeploymentConfigFile is the file representation of DeploymentConfig.
+type DeploymentConfigFile struct {
+	Name               string                       `yaml:"name"`
+	Namespace          string                       `yaml:"namespace"`
+	ContainerName      string                       `yaml:"containerName"`
+	Image              string                       `yaml:"image"`
+	ImagePullPolicy    corev1.PullPolicy            `yaml:"imagePullPolicy"`
+	ImagePullSecret    string                       `yaml:"imagePullSecret"`
+	RequireImageDigest bool                         `yaml:"requireImageDigest"`
+	ImageRewriteRules  []ImageRewriteRuleConfigFile `yaml:"imageRewriteRules"`
+	// defaults to 1
+	Replicas          *int32              `yaml:"replicas"`
+	PortName          string              `yaml:"portName"`
+	ContainerPort     int32               `yaml:"containerPort"`
+	Env               map[string]string   `yaml:"env"`
+	EnvFromConfigMaps []string            `yaml:"envFromConfigMaps"`
+	EnvFromSecrets    []string            `yaml:"envFromSecrets"`
+	PodLabels         map[string]string   `yaml:"podLabels"`
+	MatchLabels       map[string]string   `yaml:"matchLabels"`
+	Requests          ResourcesConfigFile `yaml:"requests"`
+	Limits            ResourcesConfigFile `yaml:"limits"`
+	LivenessProbe     ProbeConfigFile     `yaml:"livenessProbe"`
+	ReadinessProbe    ProbeConfigFile     `yaml:"readinessProbe"`
+	Volumes           ConfigVolumes       `yaml:"volumes"`
+	VolumeMounts      ConfigVolumeMounts  `yaml:"volumeMounts"`
+	// octal file mode, e.g. 0644
+	DefaultConfigMapVolumeMode int32 `yaml:"defaultConfigMapVolumeMode"`
+}
+
+func (f ProbeConfigFile) toProbeSpec() ProbeSpec {
+	return ProbeSpec{
+		HttpGetPath:         f.Path,
+		HttpGetPort:         f.Port,
+		InitialDelaySeconds: f.InitialDelay.seconds(),
+		TimeoutSeconds:      f.Timeout.seconds(),
+		PeriodSeconds:       f.Period.seconds(),
+		FailureThreshold:    f.FailureThreshold,
+		SuccessThreshold:    f.SuccessThreshold,
+	}
+}
+
+// memory in Mi, rounded up
+func mebibytes(q ConfigQuantity) int64 {
+	return int64(math.Ceil(float64(q.Value()) / (1024 * 1024)))
+}
+
+// ToDeploymentConfig converts the file representation; the container name
+// defaults to the deployment name and replicas default to 1.
+func (f DeploymentConfigFile) ToDeploymentConfig() DeploymentConfig {
+	var config DeploymentConfig = DeploymentConfig{
+		Name:                       f.Name,
+		Namespace:                  f.Namespace,
+		Volumes:                    f.Volumes,
+		ImagePullSecretName:        f.ImagePullSecret,
+		ContainerName:              f.ContainerName,
+		Image:                      f.Image,
+		PortName:                   f.PortName,
+		EnvFromSecretNames:         f.EnvFromSecrets,
+		EnvFromConfigMapNames:      f.EnvFromConfigMaps,
+		VolumeMounts:               f.VolumeMounts,
+		ImagePullPolicy:            f.ImagePullPolicy,
+		ContainerPort:              f.ContainerPort,
+		DefaultConfigMapVolumeMode: f.DefaultConfigMapVolumeMode,
+		Replicas:                   1,
+		EnvVarData:                 f.Env,
+		PodLabels:                  f.PodLabels,
+		MatchLabels:                f.MatchLabels,
+		CpuRequestMilli:            f.Requests.Cpu.MilliValue(),
+		CpuLimitMilli:              f.Limits.Cpu.MilliValue(),
+		MemoryRequestMi:            mebibytes(f.Requests.Memory),
+		MemoryLimitMi:              mebibytes(f.Limits.Memory),
+		LivenessProbeSpec:          f.LivenessProbe.toProbeSpec(),
+		ReadinessProbeSpec:         f.ReadinessProbe.toProbeSpec(),
+		RequireImageDigest:         f.RequireImageDigest,
+	}
+	if f.Replicas != nil {
+		config.Replicas = *f.Replicas
+	}
+	if config.ContainerName == "" {
+		config.ContainerName = f.Name
+	}
+	for _, rule := range f.ImageRewriteRules {
+		config.ImageRewriteRules = append(config.ImageRewriteRules,
+			ImageRewriteRule{Prefix: rule.Prefix, Replacement: rule.Replacement})
+	}
+	return config
+}
+
+// IngressConfigFile holds the arguments of GenerateIngress.
+type IngressConfigFile struct {
+	Name             string              `yaml:"name"`
+	Namespace        string              `yaml:"namespace"`
+	DnsUri           string              `yaml:"dnsUri"`
+	IngressBaseUrl   string              `yaml:"ingressBaseUrl"`
+	ServiceName      string              `yaml:"servicePortName"`
+	Path             string              `yaml:"path"`
+	IngressClassName string              `yaml:"ingressClassName"`
+	K8sServiceName   string              `yaml:"serviceName"`
+	PathType         networking.PathType `yaml:"pathType"`
+}
+
+func (f IngressConfigFile) Generate() networking.Ingress {
+	return GenerateIngress(f.Name, f.Namespace, f.DnsUri, f.IngressBaseUrl, f.ServiceName, f.Path,
+		f.IngressClassName, f.K8sServiceName, f.PathType)
+}
+
+// DataConfigFile holds the arguments of GenerateSecret and GenerateConfigMap.
+type DataConfigFile struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Data      map[string]string `yaml:"data"`
+}
+
+func (f DataConfigFile) GenerateSecret() corev1.Secret {
+	return GenerateSecret(f.Name, f.Namespace, f.Data)
+}
+
+func (f DataConfigFile) GenerateConfigMap() corev1.ConfigMap {
+	return GenerateConfigMap(f.Name, f.Namespace, f.Data)
+}
+
+type AppIngressConfigFile struct {
+	DnsUri           string              `yaml:"dnsUri"`
+	IngressBaseUrl   string              `yaml:"ingressBaseUrl"`
+	Path             string              `yaml:"path"`
+	IngressClassName string              `yaml:"ingressClassName"`
+	PathType         networking.PathType `yaml:"pathType"`
+}
+
+type AppAutoscalingConfigFile struct {
+	MinReplicas          int32 `yaml:"minReplicas"`
+	MaxReplicas          int32 `yaml:"maxReplicas"`
+	TargetCpuUtilization int32 `yaml:"targetCpuUtilization"`
+}
+
+type AppDisruptionBudgetConfigFile struct {
+	// number or percentage, e.g. 1 or "50%"
+	MinAvailable string `yaml:"minAvailable"`
+}
+
+// AppConfigFile is the file representation of AppConfig.
+type AppConfigFile struct {
+	Name             string                         `yaml:"name"`
+	Namespace        string                         `yaml:"namespace"`
+	Labels           map[string]string              `yaml:"labels"`
+	ServicePort      int32                          `yaml:"servicePort"`
+	PortName         string                         `yaml:"portName"`
+	Config           map[string]string              `yaml:"config"`
+	Secrets          map[string]string              `yaml:"secrets"`
+	HashConfig       bool                           `yaml:"hashConfig"`
+	Deployment       DeploymentConfigFile           `yaml:"deployment"`
+	Ingress          *AppIngressConfigFile          `yaml:"ingress"`
+	Autoscaling      *AppAutoscalingConfigFile      `yaml:"autoscaling"`
+	DisruptionBudget *AppDisruptionBudgetConfigFile `yaml:"disruptionBudget"`
+}
+
+func (f AppConfigFile) ToAppConfig() AppConfig {
+	var config AppConfig = AppConfig{
+		Name:        f.Name,
+		Namespace:   f.Namespace,
+		Labels:      f.Labels,
+		ServicePort: f.ServicePort,
+		PortName:    f.PortName,
+		ConfigData:  f.Config,
+		SecretData:  f.Secrets,
+		HashConfig:  f.HashConfig,
+		Deployment:  f.Deployment.ToDeploymentConfig(),
+	}
+	if f.Ingress != nil {
+		config.Ingress = &AppIngressConfig{
+			DnsUri:           f.Ingress.DnsUri,
+			IngressBaseUrl:   f.Ingress.IngressBaseUrl,
+			Path:             f.Ingress.Path,
+			IngressClassName: f.Ingress.IngressClassName,
+			PathType:         f.Ingress.PathType,
+		}
+	}
+	if f.Autoscaling != nil {
+		config.Autoscaling = &AppAutoscalingConfig{
+			MinReplicas:          f.Autoscaling.MinReplicas,
+			MaxReplicas:          f.Autoscaling.MaxReplicas,
+			TargetCpuUtilization: f.Autoscaling.TargetCpuUtilization,
+		}
+	}
+	if f.DisruptionBudget != nil {
+		config.DisruptionBudget = &AppDisruptionBudgetConfig{
+			MinAvailable: intstr.Parse(f.DisruptionBudget.MinAvailable),
+		}
+	}
+	return config
+}
+
+// LoadConfigFiles reads YAML (or JSON) files into a config file struct. The
+// first file is the base, every following file (e.g. per environment)
+// overrides it: maps are merged key by key, lists and values are replaced
+// and null removes a value. Unknown fields are rejected with file name and
+// line number.
+func LoadConfigFiles[T any](paths ...string) (T, error) {
+	var result T
+	if len(paths) == 0 {
+		return result, errors.New("no config file given")
+	}
+	var merged map[string]interface{} = map[string]interface{}{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return result, err
+		}
+		layer, err := decodeConfigLayer[T](data)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", path, err)
+		}
+		merged = mergeConfigLayers(merged, layer)
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return result, err
+	}
+	if err := strictYAMLDecode(data, &result); err != nil {
+		return result, fmt.Errorf("merged config of %v: %w", paths, err)
+	}
+	return result, nil
+}
+
+// validates one file strictly against T and returns its content as map
+func decodeConfigLayer[T any](data []byte) (map[string]interface{}, error) {
+	var check T
+	if err := strictYAMLDecode(data, &check); err != nil {
+		return nil, err
+	}
+	var layer map[string]interface{}
+	if err := yaml.Unmarshal(data, &layer); err != nil {
+		return nil, err
+	}
+	if layer == nil {
+		layer = map[string]interface{}{}
+	}
+	return layer, nil
+}
+
+func strictYAMLDecode(data []byte, target interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(target)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func mergeConfigLayers(base, override map[string]interface{}) map[string]interface{} {
+	for key, value := range override {
+		if value == nil {
+			delete(base, key)
+			continue
+		}
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		baseMap, baseIsMap := base[key].(map[string]interface{})
+		if overrideIsMap && baseIsMap {
+			base[key] = mergeConfigLayers(baseMap, overrideMap)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
+
+// LoadDeploymentConfig loads a DeploymentConfig from layered files, see
+// LoadConfigFiles.
+func LoadDeploymentConfig(paths ...string) (DeploymentConfig, error) {
+	file, err := LoadConfigFiles[DeploymentConfigFile](paths...)
+	if err != nil {
+		return DeploymentConfig{}, err
+	}
+	return file.ToDeploymentConfig(), nil
+}
+
+// LoadAppConfig loads an AppConfig from layered files, see LoadConfigFiles.
+func LoadAppConfig(paths ...string) (AppConfig, error) {
+	file, err := LoadConfigFiles[AppConfigFile](paths...)
+	if err != nil {
+		return AppConfig{}, err
+	}
+	return file.ToAppConfig(), nil
+}
@@ -0,0 +1,118 @@
+package negotools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+const testBaseConfig = `
+name: spam
+namespace: eggs
+image: registry.example.com/spam:1.2.3
+portName: http
+containerPort: 8080
+env:
+  LOG_LEVEL: info
+  FEATURE: "on"
+requests:
+  cpu: 250m
+  memory: 128Mi
+limits:
+  cpu: "1"
+  memory: 0.5Gi
+livenessProbe:
+  path: /healthz
+  port: 8080
+  initialDelay: 1m
+  timeout: 5
+  period: 10s
+volumes:
+  - name: config
+    configMap:
+      name: spam-config
+`
+
+func TestLoadDeploymentConfig(t *testing.T) {
+	base := writeConfigFile(t, "base.yaml", testBaseConfig)
+	prod := writeConfigFile(t, "prod.yaml", `
+replicas: 3
+env:
+  LOG_LEVEL: warn
+  FEATURE: null
+limits:
+  memory: 1Gi
+`)
+
+	config, err := LoadDeploymentConfig(base, prod)
+	assert.NoError(t, err)
+	assert.Equal(t, "spam", config.ContainerName)
+	assert.Equal(t, int32(3), config.Replicas)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "warn"}, config.EnvVarData)
+	assert.Equal(t, int64(250), config.CpuRequestMilli)
+	assert.Equal(t, int64(1000), config.CpuLimitMilli)
+	assert.Equal(t, int64(128), config.MemoryRequestMi)
+	assert.Equal(t, int64(1024), config.MemoryLimitMi)
+	assert.Equal(t, int32(60), config.LivenessProbeSpec.InitialDelaySeconds)
+	assert.Equal(t, int32(5), config.LivenessProbeSpec.TimeoutSeconds)
+	assert.Equal(t, int32(10), config.LivenessProbeSpec.PeriodSeconds)
+	assert.Equal(t, "spam-config", config.Volumes[0].ConfigMap.Name)
+
+	config, err = LoadDeploymentConfig(base)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), config.Replicas)
+	assert.Equal(t, int64(512), config.MemoryLimitMi)
+}
+
+func TestLoadConfigFilesErrors(t *testing.T) {
+	base := writeConfigFile(t, "base.yaml", testBaseConfig)
+
+	unknown := writeConfigFile(t, "unknown.yaml", "replicas: 2\nlimts:\n  cpu: 1\n")
+	_, err := LoadDeploymentConfig(base, unknown)
+	assert.ErrorContains(t, err, "unknown.yaml")
+	assert.ErrorContains(t, err, "line 2: field limts not found")
+
+	quantity := writeConfigFile(t, "quantity.yaml", "requests:\n  cpu: lots\n")
+	_, err = LoadDeploymentConfig(base, quantity)
+	assert.ErrorContains(t, err, `line 2: invalid quantity "lots"`)
+
+	duration := writeConfigFile(t, "duration.yaml", "readinessProbe:\n  period: 1500ms\n")
+	_, err = LoadDeploymentConfig(base, duration)
+	assert.ErrorContains(t, err, "whole number of seconds")
+
+	volume := writeConfigFile(t, "volume.yaml", "volumes:\n  - name: data\n    emptyDirr: {}\n")
+	_, err = LoadDeploymentConfig(base, volume)
+	assert.ErrorContains(t, err, "line 2")
+	assert.ErrorContains(t, err, "emptyDirr")
+
+	_, err = LoadDeploymentConfig()
+	assert.Error(t, err)
+}
+
+func TestLoadAppConfig(t *testing.T) {
+	path := writeConfigFile(t, "app.json", `{
+  "name": "spam",
+  "namespace": "eggs",
+  "config": {"LOG_LEVEL": "info"},
+  "deployment": {"name": "spam", "namespace": "eggs", "image": "spam:1", "containerPort": 8080},
+  "ingress": {"dnsUri": "spam", "ingressBaseUrl": "example.com", "path": "/", "pathType": "Prefix"},
+  "disruptionBudget": {"minAvailable": "50%"}
+}`)
+
+	config, err := LoadAppConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "info", config.ConfigData["LOG_LEVEL"])
+	assert.Equal(t, "example.com", config.Ingress.IngressBaseUrl)
+	assert.Nil(t, config.Autoscaling)
+	assert.Equal(t, intstr.FromString("50%"), config.DisruptionBudget.MinAvailable)
+	assert.NoError(t, GenerateApp(config).CheckReferences())
+}
@@ -0,0 +1,355 @@
+package negotools
+
+// composite generator for a complete, consistently wired application
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	AppNameLabel      string = "app.kubernetes.io/name"
+	AppManagedByLabel string = "app.kubernetes.io/managed-by"
+	defaultPortName   string = "http"
+	defaultPort       int32  = 80
+)
+
+type AppIngressConfig struct {
+	DnsUri           string
+	IngressBaseUrl   string
+	Path             string
+	IngressClassName string
+	PathType         networking.PathType
+}
+
+type AppAutoscalingConfig struct {
+	MinReplicas          int32
+	MaxReplicas          int32
+	TargetCpuUtilization int32
+}
+
+type AppDisruptionBudgetConfig struct {
+	MinAvailable intstr.IntOrString
+}
+
+// AppConfig describes an application from which GenerateApp derives all
+// objects. Name, Namespace, labels, port names and envFrom references are set
+// by GenerateApp; the corresponding fields of Deployment are overwritten,
+// all other Deployment fields (image, resources, probes, ...) are used as is.
+type AppConfig struct {
+	Name      string
+	Namespace string
+	// added to all objects, the selector only uses AppNameLabel
+	Labels map[string]string
+	// port the Service listens on, defaults to 80
+	ServicePort int32
+	// port name shared by container, Service and Ingress, defaults to "http"
+	PortName   string
+	ConfigData map[string]string
+	SecretData map[string]string
+	// make ConfigMap and Secret immutable and append a hash of their content
+	// to their names, see HashConfigMap
+	HashConfig bool
+	Deployment DeploymentConfig
+	// optional objects, not generated if nil
+	Ingress          *AppIngressConfig
+	Autoscaling      *AppAutoscalingConfig
+	DisruptionBudget *AppDisruptionBudgetConfig
+}
+
+// AppBundle holds the objects generated for an AppConfig. Optional objects
+// are nil if they were not requested.
+type AppBundle struct {
+	ConfigMap               *corev1.ConfigMap
+	Secret                  *corev1.Secret
+	Deployment              *appsv1.Deployment
+	Service                 *corev1.Service
+	Ingress                 *networking.Ingress
+	HorizontalPodAutoscaler *autoscalingv2.HorizontalPodAutoscaler
+	PodDisruptionBudget     *policyv1.PodDisruptionBudget
+}
+
+// names of the objects generated for an app
+func (config AppConfig) configMapName() string {
+	return DerivedName(config.Name, ConfigMapNameSuffix)
+}
+
+func (config AppConfig) secretName() string {
+	return DerivedName(config.Name, SecretNameSuffix)
+}
+
+// selector labels of the app's pods
+func (config AppConfig) selectorLabels() map[string]string {
+	return map[string]string{AppNameLabel: config.Name}
+}
+
+// labels put on every object of the app
+func (config AppConfig) objectLabels() map[string]string {
+	var objectLabels map[string]string = map[string]string{AppManagedByLabel: DefaultFieldManager}
+	for key, value := range config.Labels {
+		objectLabels[key] = value
+	}
+	for key, value := range config.selectorLabels() {
+		objectLabels[key] = value
+	}
+	return objectLabels
+}
+
+func (config AppConfig) portName() string {
+	if config.PortName == "" {
+		return defaultPortName
+	}
+	return config.PortName
+}
+
+// the Deployment input with all fields GenerateApp wires set
+func (config AppConfig) deploymentConfig() DeploymentConfig {
+	var deploymentConfig DeploymentConfig = config.Deployment
+	deploymentConfig.Name = config.Name
+	deploymentConfig.Namespace = config.Namespace
+	deploymentConfig.PortName = config.portName()
+	deploymentConfig.PodLabels = config.objectLabels()
+	deploymentConfig.MatchLabels = config.selectorLabels()
+	if deploymentConfig.ContainerName == "" {
+		deploymentConfig.ContainerName = config.Name
+	}
+	if deploymentConfig.LivenessProbeSpec.HttpGetPort == 0 {
+		deploymentConfig.LivenessProbeSpec.HttpGetPort = deploymentConfig.ContainerPort
+	}
+	if deploymentConfig.ReadinessProbeSpec.HttpGetPort == 0 {
+		deploymentConfig.ReadinessProbeSpec.HttpGetPort = deploymentConfig.ContainerPort
+	}
+	deploymentConfig.EnvFromConfigMapNames = nil
+	deploymentConfig.EnvFromSecretNames = nil
+	if len(config.ConfigData) > 0 {
+		deploymentConfig.EnvFromConfigMapNames = []string{config.configMapName()}
+	}
+	if len(config.SecretData) > 0 {
+		deploymentConfig.EnvFromSecretNames = []string{config.secretName()}
+	}
+	return deploymentConfig
+}
+
+// GenerateApp generates ConfigMap, Secret, Deployment, Service and the
+// optional Ingress, HorizontalPodAutoscaler and PodDisruptionBudget of an
+// app, wired to each other by name, labels and port name. With Autoscaling
+// the Deployment has no replicas, every apply would reset the scale of the
+// autoscaler otherwise.
+func GenerateApp(config AppConfig) AppBundle {
+	var bundle AppBundle = AppBundle{}
+	var portName string = config.portName()
+	var servicePort int32 = config.ServicePort
+	if servicePort == 0 {
+		servicePort = defaultPort
+	}
+	var deploymentConfig DeploymentConfig = config.deploymentConfig()
+
+	if len(config.ConfigData) > 0 {
+		configMap := GenerateConfigMap(config.configMapName(), config.Namespace, config.ConfigData)
+		configMap.Labels = config.objectLabels()
+		if config.HashConfig {
+			configMap = HashConfigMap(configMap)
+			deploymentConfig = deploymentConfig.WithHashedNames([]corev1.ConfigMap{configMap}, nil)
+		}
+		bundle.ConfigMap = &configMap
+	}
+	if len(config.SecretData) > 0 {
+		secret := GenerateSecret(config.secretName(), config.Namespace, config.SecretData)
+		secret.Labels = config.objectLabels()
+		if config.HashConfig {
+			secret = HashSecret(secret)
+			deploymentConfig = deploymentConfig.WithHashedNames(nil, []corev1.Secret{secret})
+		}
+		bundle.Secret = &secret
+	}
+
+	deployment := GenerateDeployment(deploymentConfig)
+	deployment.Labels = config.objectLabels()
+	bundle.Deployment = &deployment
+
+	service := GenerateService(config.Name, config.Namespace, config.selectorLabels(),
+		portName, servicePort, intstr.FromString(portName))
+	service.Labels = config.objectLabels()
+	bundle.Service = &service
+
+	if config.Ingress != nil {
+		ingress := GenerateIngress(config.Name, config.Namespace,
+			config.Ingress.DnsUri, config.Ingress.IngressBaseUrl, portName, config.Ingress.Path,
+			config.Ingress.IngressClassName, service.Name, config.Ingress.PathType)
+		ingress.Labels = config.objectLabels()
+		bundle.Ingress = &ingress
+	}
+	if config.Autoscaling != nil {
+		autoscaler := GenerateHorizontalPodAutoscaler(config.Name, config.Namespace, deployment.Name,
+			config.Autoscaling.MinReplicas, config.Autoscaling.MaxReplicas, config.Autoscaling.TargetCpuUtilization)
+		autoscaler.Labels = config.objectLabels()
+		bundle.HorizontalPodAutoscaler = &autoscaler
+		deployment.Spec.Replicas = nil
+	}
+	if config.DisruptionBudget != nil {
+		budget := GeneratePodDisruptionBudget(config.Name, config.Namespace, config.selectorLabels(),
+			config.DisruptionBudget.MinAvailable)
+		budget.Labels = config.objectLabels()
+		bundle.PodDisruptionBudget = &budget
+	}
+	return bundle
+}
+
+// Objects returns the generated objects in the order ConfigMap, Secret,
+// Deployment, Service, Ingress, HorizontalPodAutoscaler,
+// PodDisruptionBudget, leaving out the ones that were not generated.
+func (bundle AppBundle) Objects() []runtime.Object {
+	var objects []runtime.Object = []runtime.Object{}
+	if bundle.ConfigMap != nil {
+		objects = append(objects, bundle.ConfigMap)
+	}
+	if bundle.Secret != nil {
+		objects = append(objects, bundle.Secret)
+	}
+	if bundle.Deployment != nil {
+		objects = append(objects, bundle.Deployment)
+	}
+	if bundle.Service != nil {
+		objects = append(objects, bundle.Service)
+	}
+	if bundle.Ingress != nil {
+		objects = append(objects, bundle.Ingress)
+	}
+	if bundle.HorizontalPodAutoscaler != nil {
+		objects = append(objects, bundle.HorizontalPodAutoscaler)
+	}
+	if bundle.PodDisruptionBudget != nil {
+		objects = append(objects, bundle.PodDisruptionBudget)
+	}
+	return objects
+}
+
+// CheckReferences verifies that all references between the objects of the
+// bundle resolve: envFrom and volume sources, selectors, Service target
+// ports, Ingress backends and the autoscaler target, which must not have
+// replicas set. All problems are returned at once as an aggregate of
+// field errors.
+func (bundle AppBundle) CheckReferences() error {
+	var errs field.ErrorList = field.ErrorList{}
+	if bundle.Deployment == nil {
+		return field.ErrorList{field.Required(field.NewPath("Deployment"), "")}.ToAggregate()
+	}
+	var deployment *appsv1.Deployment = bundle.Deployment
+	var podLabels labels.Set = labels.Set(deployment.Spec.Template.Labels)
+	var podSpecPath *field.Path = field.NewPath("Deployment", "spec", "template", "spec")
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil || selector.Empty() || !selector.Matches(podLabels) {
+		errs = append(errs, field.Invalid(field.NewPath("Deployment", "spec", "selector"),
+			deployment.Spec.Selector, "does not select the pod template labels"))
+	}
+
+	var containerPorts map[string]bool = map[string]bool{}
+	for i, container := range deployment.Spec.Template.Spec.Containers {
+		var containerPath *field.Path = podSpecPath.Child("containers").Index(i)
+		for _, port := range container.Ports {
+			containerPorts[port.Name] = true
+		}
+		for j, source := range container.EnvFrom {
+			if source.ConfigMapRef != nil && !bundle.hasConfigMap(source.ConfigMapRef.Name) {
+				errs = append(errs, field.NotFound(containerPath.Child("envFrom").Index(j).Child("configMapRef", "name"), source.ConfigMapRef.Name))
+			}
+			if source.SecretRef != nil && !bundle.hasSecret(source.SecretRef.Name) {
+				errs = append(errs, field.NotFound(containerPath.Child("envFrom").Index(j).Child("secretRef", "name"), source.SecretRef.Name))
+			}
+		}
+	}
+	for i, volume := range deployment.Spec.Template.Spec.Volumes {
+		var volumePath *field.Path = podSpecPath.Child("volumes").Index(i)
+		if volume.ConfigMap != nil && !bundle.hasConfigMap(volume.ConfigMap.Name) {
+			errs = append(errs, field.NotFound(volumePath.Child("configMap", "name"), volume.ConfigMap.Name))
+		}
+		if volume.Secret != nil && !bundle.hasSecret(volume.Secret.SecretName) {
+			errs = append(errs, field.NotFound(volumePath.Child("secret", "secretName"), volume.Secret.SecretName))
+		}
+	}
+
+	if bundle.Service != nil {
+		var servicePath *field.Path = field.NewPath("Service", "spec")
+		if len(bundle.Service.Spec.Selector) == 0 ||
+			!labels.SelectorFromSet(bundle.Service.Spec.Selector).Matches(podLabels) {
+			errs = append(errs, field.Invalid(servicePath.Child("selector"), bundle.Service.Spec.Selector,
+				"does not select the pods of the Deployment"))
+		}
+		for i, port := range bundle.Service.Spec.Ports {
+			if port.TargetPort.Type == intstr.String && !containerPorts[port.TargetPort.StrVal] {
+				errs = append(errs, field.NotFound(servicePath.Child("ports").Index(i).Child("targetPort"), port.TargetPort.StrVal))
+			}
+		}
+	}
+
+	if bundle.Ingress != nil {
+		for i, rule := range bundle.Ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for j, path := range rule.HTTP.Paths {
+				var backendPath *field.Path = field.NewPath("Ingress", "spec", "rules").Index(i).
+					Child("http", "paths").Index(j).Child("backend", "service")
+				errs = append(errs, bundle.checkIngressBackend(backendPath, path.Backend.Service)...)
+			}
+		}
+	}
+
+	if bundle.HorizontalPodAutoscaler != nil {
+		var target autoscalingv2.CrossVersionObjectReference = bundle.HorizontalPodAutoscaler.Spec.ScaleTargetRef
+		if target.Kind != "Deployment" || target.Name != deployment.Name {
+			errs = append(errs, field.NotFound(field.NewPath("HorizontalPodAutoscaler", "spec", "scaleTargetRef"),
+				fmt.Sprintf("%s/%s", target.Kind, target.Name)))
+		}
+		if deployment.Spec.Replicas != nil {
+			errs = append(errs, field.Forbidden(field.NewPath("Deployment", "spec", "replicas"),
+				"must not be set when a HorizontalPodAutoscaler scales the Deployment"))
+		}
+	}
+	if bundle.PodDisruptionBudget != nil {
+		budgetSelector, err := metav1.LabelSelectorAsSelector(bundle.PodDisruptionBudget.Spec.Selector)
+		if err != nil || budgetSelector.Empty() || !budgetSelector.Matches(podLabels) {
+			errs = append(errs, field.Invalid(field.NewPath("PodDisruptionBudget", "spec", "selector"),
+				bundle.PodDisruptionBudget.Spec.Selector, "does not select the pods of the Deployment"))
+		}
+	}
+	return errs.ToAggregate()
+}
+
+func (bundle AppBundle) hasConfigMap(name string) bool {
+	return bundle.ConfigMap != nil && bundle.ConfigMap.Name == name
+}
+
+func (bundle AppBundle) hasSecret(name string) bool {
+	return bundle.Secret != nil && bundle.Secret.Name == name
+}
+
+func (bundle AppBundle) checkIngressBackend(path *field.Path, backend *networking.IngressServiceBackend) field.ErrorList {
+	if backend == nil {
+		return nil
+	}
+	if bundle.Service == nil || backend.Name != bundle.Service.Name {
+		return field.ErrorList{field.NotFound(path.Child("name"), backend.Name)}
+	}
+	for _, port := range bundle.Service.Spec.Ports {
+		if (backend.Port.Name != "" && port.Name == backend.Port.Name) ||
+			(backend.Port.Name == "" && port.Port == backend.Port.Number) {
+			return nil
+		}
+	}
+	if backend.Port.Name != "" {
+		return field.ErrorList{field.NotFound(path.Child("port", "name"), backend.Port.Name)}
+	}
+	return field.ErrorList{field.NotFound(path.Child("port", "number"), backend.Port.Number)}
+}
@@ -0,0 +1,110 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func testAppConfig() AppConfig {
+	return AppConfig{
+		Name:       "spam",
+		Namespace:  "eggs",
+		Labels:     map[string]string{"team": "python"},
+		ConfigData: map[string]string{"LOG_LEVEL": "info"},
+		SecretData: map[string]string{"DB_PASSWORD": "ham"},
+		Deployment: testDeploymentConfig(),
+		Ingress: &AppIngressConfig{
+			DnsUri:           "spam",
+			IngressBaseUrl:   "example.com",
+			Path:             "/",
+			IngressClassName: "nginx",
+			PathType:         networking.PathTypePrefix,
+		},
+		Autoscaling:      &AppAutoscalingConfig{MinReplicas: 2, MaxReplicas: 5, TargetCpuUtilization: 80},
+		DisruptionBudget: &AppDisruptionBudgetConfig{MinAvailable: intstr.FromInt32(1)},
+	}
+}
+
+func TestGenerateApp(t *testing.T) {
+	bundle := GenerateApp(testAppConfig())
+	assert.NoError(t, bundle.CheckReferences())
+
+	objects := bundle.Objects()
+	assert.Len(t, objects, 7)
+	assert.IsType(t, &corev1.ConfigMap{}, objects[0])
+	assert.IsType(t, &corev1.Secret{}, objects[1])
+	assert.IsType(t, &appsv1.Deployment{}, objects[2])
+	assert.IsType(t, &corev1.Service{}, objects[3])
+	assert.IsType(t, &networking.Ingress{}, objects[4])
+	assert.IsType(t, &autoscalingv2.HorizontalPodAutoscaler{}, objects[5])
+	assert.IsType(t, &policyv1.PodDisruptionBudget{}, objects[6])
+
+	var container corev1.Container = bundle.Deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "spam-config", container.EnvFrom[0].ConfigMapRef.Name)
+	assert.Equal(t, "spam-secret", container.EnvFrom[1].SecretRef.Name)
+	assert.Equal(t, "http", container.Ports[0].Name)
+	assert.Equal(t, intstr.FromString("http"), bundle.Service.Spec.Ports[0].TargetPort)
+	assert.Equal(t, int32(80), bundle.Service.Spec.Ports[0].Port)
+	assert.Equal(t, "spam", bundle.Ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name)
+	assert.Equal(t, map[string]string{AppNameLabel: "spam"}, bundle.Service.Spec.Selector)
+	assert.Equal(t, "python", bundle.ConfigMap.Labels["team"])
+	assert.Equal(t, "python", bundle.Deployment.Spec.Template.Labels["team"])
+	// the autoscaler owns the scale
+	assert.Nil(t, bundle.Deployment.Spec.Replicas)
+}
+
+func TestGenerateAppMinimal(t *testing.T) {
+	config := testAppConfig()
+	config.ConfigData = nil
+	config.SecretData = nil
+	config.Ingress = nil
+	config.Autoscaling = nil
+	config.DisruptionBudget = nil
+	bundle := GenerateApp(config)
+	assert.NoError(t, bundle.CheckReferences())
+	assert.Len(t, bundle.Objects(), 2)
+	assert.Equal(t, int32(2), *bundle.Deployment.Spec.Replicas)
+	assert.Empty(t, bundle.Deployment.Spec.Template.Spec.Containers[0].EnvFrom)
+}
+
+func TestAppBundleCheckReferences(t *testing.T) {
+	bundle := GenerateApp(testAppConfig())
+	bundle.Service.Spec.Selector = map[string]string{AppNameLabel: "ham"}
+	bundle.Service.Spec.Ports[0].TargetPort = intstr.FromString("https")
+	bundle.Ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Name = "https"
+	bundle.ConfigMap.Name = "spam-config-v2"
+	bundle.HorizontalPodAutoscaler.Spec.ScaleTargetRef.Name = "ham"
+	var replicas int32 = 3
+	bundle.Deployment.Spec.Replicas = &replicas
+
+	err := bundle.CheckReferences()
+	assert.Equal(t, []string{
+		"Deployment.spec.template.spec.containers[0].envFrom[0].configMapRef.name",
+		"Service.spec.selector",
+		"Service.spec.ports[0].targetPort",
+		"Ingress.spec.rules[0].http.paths[0].backend.service.port.name",
+		"HorizontalPodAutoscaler.spec.scaleTargetRef",
+		"Deployment.spec.replicas",
+	}, fieldErrorPaths(t, err))
+}
+
+func TestGenerateAppHashConfig(t *testing.T) {
+	config := testAppConfig()
+	config.HashConfig = true
+	bundle := GenerateApp(config)
+	assert.NoError(t, bundle.CheckReferences())
+	assert.Regexp(t, `^spam-config-[0-9a-f]{8}$`, bundle.ConfigMap.Name)
+	assert.True(t, *bundle.Secret.Immutable)
+
+	var envFrom []corev1.EnvFromSource = bundle.Deployment.Spec.Template.Spec.Containers[0].EnvFrom
+	assert.Equal(t, bundle.ConfigMap.Name, envFrom[0].ConfigMapRef.Name)
+	assert.Equal(t, bundle.Secret.Name, envFrom[1].SecretRef.Name)
+	assert.Equal(t, "python", bundle.ConfigMap.Labels["team"])
+}
@@ -0,0 +1,229 @@
+package negotools
+
+// create-or-update of generated objects via server-side apply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// field manager used for server-side apply if none is configured
+const DefaultFieldManager string = "ne-go-tools"
+
+type ApplyResult string
+
+const (
+	ApplyResultCreated   ApplyResult = "created"
+	ApplyResultUpdated   ApplyResult = "updated"
+	ApplyResultUnchanged ApplyResult = "unchanged"
+)
+
+type ApplyOptions struct {
+	// field manager recorded in managedFields, defaults to DefaultFieldManager
+	FieldManager string
+	// take over fields owned by other field managers
+	Force bool
+	// called before comparing and applying with a copy of the desired object
+	// and the live object (nil if it does not exist yet); use it to carry
+	// over fields that must be preserved, e.g. replicas managed by an HPA
+	Mutate func(desired, live runtime.Object) error
+}
+
+// ApplyObject creates or updates a generated object (Secret, ConfigMap,
+// Deployment, Ingress, ...) using server-side apply. The apply request is
+// always sent, so fields the field manager no longer sets are removed; the
+// result tells whether the API server changed the object.
+func ApplyObject(
+	ctx context.Context, client kubernetes.Interface, obj runtime.Object, options ApplyOptions,
+) (ApplyResult, error) {
+
+	desired := obj.DeepCopyObject()
+	gvk, err := objectKind(desired)
+	if err != nil {
+		return "", err
+	}
+	accessor, err := meta.Accessor(desired)
+	if err != nil {
+		return "", err
+	}
+	resource, err := resourceClientFor(client, gvk, accessor.GetNamespace())
+	if err != nil {
+		return "", err
+	}
+
+	var live runtime.Object
+	live, err = resource.get(ctx, accessor.GetName())
+	if apierrors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get %s %q: %w", gvk.Kind, accessor.GetName(), err)
+	}
+
+	if options.Mutate != nil {
+		if err := options.Mutate(desired, live); err != nil {
+			return "", fmt.Errorf("failed to mutate %s %q: %w", gvk.Kind, accessor.GetName(), err)
+		}
+	}
+
+	data, err := applyPatchData(desired, gvk)
+	if err != nil {
+		return "", err
+	}
+	var fieldManager string = options.FieldManager
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+	var patchOptions metav1.PatchOptions = metav1.PatchOptions{
+		FieldManager: fieldManager,
+		Force:        &options.Force,
+	}
+	applied, err := resource.patch(ctx, accessor.GetName(), types.ApplyPatchType, data, patchOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply %s %q: %w", gvk.Kind, accessor.GetName(), err)
+	}
+	var result ApplyResult = ApplyResultCreated
+	if live != nil {
+		changed, err := applyChanged(live, applied)
+		if err != nil {
+			return "", err
+		}
+		result = ApplyResultUnchanged
+		if changed {
+			result = ApplyResultUpdated
+		}
+	}
+	LogDebug("Applied object", "Kind", gvk.Kind, "Name", accessor.GetName(), "Result", result)
+	return result, nil
+}
+
+// apply requests need apiVersion and kind and must not carry server managed
+// metadata
+func applyPatchData(obj runtime.Object, gvk schema.GroupVersionKind) ([]byte, error) {
+	obj = obj.DeepCopyObject()
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	accessor.SetManagedFields(nil)
+	accessor.SetResourceVersion("")
+	accessor.SetUID("")
+	return json.Marshal(obj)
+}
+
+// applyChanged tells whether an apply request changed the live object. The
+// API server only bumps the resourceVersion if something was written; object
+// trackers without resource versions (fake clientsets) are compared without
+// the metadata the server fills in instead.
+func applyChanged(before, after runtime.Object) (bool, error) {
+	beforeAccessor, err := meta.Accessor(before)
+	if err != nil {
+		return false, err
+	}
+	afterAccessor, err := meta.Accessor(after)
+	if err != nil {
+		return false, err
+	}
+	if beforeAccessor.GetResourceVersion() != "" || afterAccessor.GetResourceVersion() != "" {
+		return beforeAccessor.GetResourceVersion() != afterAccessor.GetResourceVersion(), nil
+	}
+	var comparable = func(obj runtime.Object) runtime.Object {
+		obj = obj.DeepCopyObject()
+		obj.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+		accessor, _ := meta.Accessor(obj)
+		accessor.SetManagedFields(nil)
+		accessor.SetCreationTimestamp(metav1.Time{})
+		return obj
+	}
+	return !equality.Semantic.DeepEqual(comparable(before), comparable(after)), nil
+}
+
+// objectKind returns the GroupVersionKind of typed objects, which usually
+// come without TypeMeta from the generators
+func objectKind(obj runtime.Object) (schema.GroupVersionKind, error) {
+	var gvk schema.GroupVersionKind = obj.GetObjectKind().GroupVersionKind()
+	if !gvk.Empty() {
+		return gvk, nil
+	}
+	kinds, _, err := manifestScheme.ObjectKinds(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	return kinds[0], nil
+}
+
+// resourceClient hides the typed clients of client-go behind one set of
+// functions per kind
+type resourceClient struct {
+	get    func(ctx context.Context, name string) (runtime.Object, error)
+	patch  func(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions) (runtime.Object, error)
+	delete func(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	list   func(ctx context.Context, opts metav1.ListOptions) ([]runtime.Object, error)
+}
+
+type typedClient[T runtime.Object, L runtime.Object] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (T, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	List(ctx context.Context, opts metav1.ListOptions) (L, error)
+}
+
+func newResourceClient[T runtime.Object, L runtime.Object](client typedClient[T, L]) resourceClient {
+	return resourceClient{
+		get: func(ctx context.Context, name string) (runtime.Object, error) {
+			return client.Get(ctx, name, metav1.GetOptions{})
+		},
+		patch: func(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions) (runtime.Object, error) {
+			return client.Patch(ctx, name, pt, data, opts)
+		},
+		delete: client.Delete,
+		list: func(ctx context.Context, opts metav1.ListOptions) ([]runtime.Object, error) {
+			list, err := client.List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			return meta.ExtractList(list)
+		},
+	}
+}
+
+func resourceClientFor(client kubernetes.Interface, gvk schema.GroupVersionKind, namespace string) (resourceClient, error) {
+	switch gvk {
+	case corev1.SchemeGroupVersion.WithKind("Namespace"):
+		return newResourceClient(client.CoreV1().Namespaces()), nil
+	case corev1.SchemeGroupVersion.WithKind("ServiceAccount"):
+		return newResourceClient(client.CoreV1().ServiceAccounts(namespace)), nil
+	case corev1.SchemeGroupVersion.WithKind("Secret"):
+		return newResourceClient(client.CoreV1().Secrets(namespace)), nil
+	case corev1.SchemeGroupVersion.WithKind("ConfigMap"):
+		return newResourceClient(client.CoreV1().ConfigMaps(namespace)), nil
+	case corev1.SchemeGroupVersion.WithKind("Service"):
+		return newResourceClient(client.CoreV1().Services(namespace)), nil
+	case appsv1.SchemeGroupVersion.WithKind("Deployment"):
+		return newResourceClient(client.AppsV1().Deployments(namespace)), nil
+	case appsv1.SchemeGroupVersion.WithKind("StatefulSet"):
+		return newResourceClient(client.AppsV1().StatefulSets(namespace)), nil
+	case networking.SchemeGroupVersion.WithKind("Ingress"):
+		return newResourceClient(client.NetworkingV1().Ingresses(namespace)), nil
+	case autoscalingv2.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"):
+		return newResourceClient(client.AutoscalingV2().HorizontalPodAutoscalers(namespace)), nil
+	case policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudget"):
+		return newResourceClient(client.PolicyV1().PodDisruptionBudgets(namespace)), nil
+	}
+	return resourceClient{}, fmt.Errorf("unsupported kind %s", gvk)
+}
@@ -0,0 +1,111 @@
+package negotools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// the fake object tracker rejects the incomplete managedFields of liveCopy
+// and does not maintain resource versions
+func fakeLiveCopy(deployment appsv1.Deployment) *appsv1.Deployment {
+	live := liveCopy(deployment)
+	live.ManagedFields = nil
+	live.ResourceVersion = ""
+	return live
+}
+
+func TestApplyObjectCreate(t *testing.T) {
+	client := fake.NewClientset()
+	configMap := GenerateConfigMap("spam", "eggs", map[string]string{"key": "value"})
+
+	result, err := ApplyObject(context.Background(), client, &configMap, ApplyOptions{FieldManager: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, ApplyResultCreated, result)
+
+	live, err := client.CoreV1().ConfigMaps("eggs").Get(context.Background(), "spam", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "value", live.Data["key"])
+}
+
+func TestApplyObjectUnchanged(t *testing.T) {
+	desired := GenerateDeployment(testDeploymentConfig())
+	client := fake.NewClientset(fakeLiveCopy(desired))
+
+	result, err := ApplyObject(context.Background(), client, &desired, ApplyOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, ApplyResultUnchanged, result)
+}
+
+func TestApplyObjectRemovedFields(t *testing.T) {
+	client := fake.NewClientset()
+	configMap := GenerateConfigMap("spam", "eggs", map[string]string{"x": "1", "y": "2"})
+	configMap.Labels = map[string]string{"app": "spam", "tier": "backend"}
+	configMap.Annotations = map[string]string{"note": "ham"}
+	result, err := ApplyObject(context.Background(), client, &configMap, ApplyOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, ApplyResultCreated, result)
+
+	// keys, labels and annotations the field manager no longer sets are removed
+	configMap = GenerateConfigMap("spam", "eggs", map[string]string{"x": "1"})
+	configMap.Labels = map[string]string{"app": "spam"}
+	result, err = ApplyObject(context.Background(), client, &configMap, ApplyOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, ApplyResultUpdated, result)
+
+	live, err := client.CoreV1().ConfigMaps("eggs").Get(context.Background(), "spam", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"x": "1"}, live.Data)
+	assert.Equal(t, map[string]string{"app": "spam"}, live.Labels)
+	assert.Empty(t, live.Annotations)
+
+	result, err = ApplyObject(context.Background(), client, &configMap, ApplyOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, ApplyResultUnchanged, result)
+}
+
+func TestApplyObjectUpdate(t *testing.T) {
+	desired := GenerateDeployment(testDeploymentConfig())
+	live := fakeLiveCopy(desired)
+	live.Spec.Template.Spec.Containers[0].Image = "registry.example.com/spam:0.9.0"
+	client := fake.NewClientset(live)
+
+	result, err := ApplyObject(context.Background(), client, &desired, ApplyOptions{Force: true})
+	assert.NoError(t, err)
+	assert.Equal(t, ApplyResultUpdated, result)
+
+	updated, err := client.AppsV1().Deployments("eggs").Get(context.Background(), "spam", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "registry.example.com/spam:1.2.3", updated.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestApplyObjectMutate(t *testing.T) {
+	desired := GenerateDeployment(testDeploymentConfig())
+	live := fakeLiveCopy(desired)
+	// scaled by an autoscaler, must be preserved
+	var replicas int32 = 7
+	live.Spec.Replicas = &replicas
+	client := fake.NewClientset(live)
+
+	var keepReplicas = func(desired, live runtime.Object) error {
+		if live != nil {
+			desired.(*appsv1.Deployment).Spec.Replicas = live.(*appsv1.Deployment).Spec.Replicas
+		}
+		return nil
+	}
+	result, err := ApplyObject(context.Background(), client, &desired, ApplyOptions{Mutate: keepReplicas})
+	assert.NoError(t, err)
+	assert.Equal(t, ApplyResultUnchanged, result)
+	assert.Equal(t, int32(2), *desired.Spec.Replicas, "the passed object must not be modified")
+}
+
+func TestApplyObjectUnsupportedKind(t *testing.T) {
+	client := fake.NewClientset()
+	_, err := ApplyObject(context.Background(), client, &appsv1.DaemonSet{}, ApplyOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+package negotools
+
+// ordered sets of generated objects with an inventory label for pruning
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// label selecting all objects of an inventory
+	InventoryLabel string = "negotools.deepshore.de/inventory"
+	// full inventory id, the label holds a hash if the id is no valid label value
+	InventoryAnnotation string = "negotools.deepshore.de/inventory-id"
+)
+
+// order in which kinds are applied; dependencies first
+var bundleKindOrder []schema.GroupVersionKind = []schema.GroupVersionKind{
+	corev1.SchemeGroupVersion.WithKind("Namespace"),
+	corev1.SchemeGroupVersion.WithKind("ServiceAccount"),
+	corev1.SchemeGroupVersion.WithKind("Secret"),
+	corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+	corev1.SchemeGroupVersion.WithKind("Service"),
+	appsv1.SchemeGroupVersion.WithKind("Deployment"),
+	appsv1.SchemeGroupVersion.WithKind("StatefulSet"),
+	autoscalingv2.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"),
+	policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudget"),
+	networking.SchemeGroupVersion.WithKind("Ingress"),
+}
+
+// ObjectKey identifies an object by kind, namespace and name.
+type ObjectKey struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+}
+
+func (k ObjectKey) String() string {
+	if k.Namespace == "" {
+		return fmt.Sprintf("%s/%s", k.GroupVersionKind.Kind, k.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", k.GroupVersionKind.Kind, k.Namespace, k.Name)
+}
+
+// Bundle collects generated objects belonging to one inventory, e.g. one
+// custom resource of an operator or one application.
+type Bundle struct {
+	InventoryID string
+	objects     map[ObjectKey]runtime.Object
+}
+
+func NewBundle(inventoryID string) *Bundle {
+	return &Bundle{InventoryID: inventoryID, objects: map[ObjectKey]runtime.Object{}}
+}
+
+// value of InventoryLabel for the bundle
+func (b *Bundle) inventoryLabelValue() string {
+	if len(validation.IsValidLabelValue(b.InventoryID)) == 0 && b.InventoryID != "" {
+		return b.InventoryID
+	}
+	return "inventory-" + strings.ToLower(CRC32Checksum(b.InventoryID))
+}
+
+// Selector returns the label selector matching all objects of the inventory.
+func (b *Bundle) Selector() labels.Selector {
+	return labels.SelectorFromSet(labels.Set{InventoryLabel: b.inventoryLabelValue()})
+}
+
+// Add stores copies of the objects, stamped with the inventory label and
+// annotation. Only kinds the bundle can order and prune are accepted; adding
+// an object with the same kind, namespace and name twice is an error.
+func (b *Bundle) Add(objects ...runtime.Object) error {
+	for _, obj := range objects {
+		obj = obj.DeepCopyObject()
+		gvk, err := objectKind(obj)
+		if err != nil {
+			return err
+		}
+		if kindRank(gvk) == len(bundleKindOrder) {
+			return fmt.Errorf("unsupported kind %s", gvk)
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		var key ObjectKey = ObjectKey{GroupVersionKind: gvk, Namespace: accessor.GetNamespace(), Name: accessor.GetName()}
+		if _, ok := b.objects[key]; ok {
+			return fmt.Errorf("duplicate object %s", key)
+		}
+
+		var objectLabels map[string]string = accessor.GetLabels()
+		if objectLabels == nil {
+			objectLabels = map[string]string{}
+		}
+		objectLabels[InventoryLabel] = b.inventoryLabelValue()
+		accessor.SetLabels(objectLabels)
+		var annotations map[string]string = accessor.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[InventoryAnnotation] = b.InventoryID
+		accessor.SetAnnotations(annotations)
+		b.objects[key] = obj
+	}
+	return nil
+}
+
+// Keys returns the keys of all objects in apply order.
+func (b *Bundle) Keys() []ObjectKey {
+	var keys []ObjectKey = make([]ObjectKey, 0, len(b.objects))
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		var rankI, rankJ int = kindRank(keys[i].GroupVersionKind), kindRank(keys[j].GroupVersionKind)
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+// Objects returns the objects in apply order: Namespaces and ServiceAccounts
+// first, then Secrets, ConfigMaps, Services, workloads and finally Ingresses.
+func (b *Bundle) Objects() []runtime.Object {
+	var objects []runtime.Object = []runtime.Object{}
+	for _, key := range b.Keys() {
+		objects = append(objects, b.objects[key])
+	}
+	return objects
+}
+
+// Apply applies all objects in order with ApplyObject and stops at the
+// first error.
+func (b *Bundle) Apply(ctx context.Context, client kubernetes.Interface, options ApplyOptions) (map[ObjectKey]ApplyResult, error) {
+	var results map[ObjectKey]ApplyResult = map[ObjectKey]ApplyResult{}
+	for _, key := range b.Keys() {
+		result, err := ApplyObject(ctx, client, b.objects[key], options)
+		if err != nil {
+			return results, err
+		}
+		results[key] = result
+	}
+	return results, nil
+}
+
+// PruneCandidates lists the live objects carrying the inventory label that
+// are no longer part of the bundle. Namespaced kinds are searched in the
+// given namespaces, or in the namespaces of the bundle's objects if none are
+// given. Namespaces themselves are never pruned.
+func (b *Bundle) PruneCandidates(ctx context.Context, client kubernetes.Interface, namespaces ...string) ([]ObjectKey, error) {
+	if len(namespaces) == 0 {
+		var seen map[string]bool = map[string]bool{}
+		for key := range b.objects {
+			if key.Namespace != "" && !seen[key.Namespace] {
+				seen[key.Namespace] = true
+				namespaces = append(namespaces, key.Namespace)
+			}
+		}
+		sort.Strings(namespaces)
+	}
+
+	var candidates []ObjectKey = []ObjectKey{}
+	var listOptions metav1.ListOptions = metav1.ListOptions{LabelSelector: b.Selector().String()}
+	for _, gvk := range bundleKindOrder {
+		if gvk.Kind == "Namespace" {
+			continue
+		}
+		for _, namespace := range namespaces {
+			resource, err := resourceClientFor(client, gvk, namespace)
+			if err != nil {
+				return nil, err
+			}
+			live, err := resource.list(ctx, listOptions)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s in %q: %w", gvk.Kind, namespace, err)
+			}
+			for _, obj := range live {
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					return nil, err
+				}
+				var key ObjectKey = ObjectKey{GroupVersionKind: gvk, Namespace: accessor.GetNamespace(), Name: accessor.GetName()}
+				if _, ok := b.objects[key]; !ok {
+					candidates = append(candidates, key)
+				}
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// Prune deletes the PruneCandidates in reverse apply order and returns the
+// deleted objects. Objects that are already gone are ignored.
+func (b *Bundle) Prune(ctx context.Context, client kubernetes.Interface, namespaces ...string) ([]ObjectKey, error) {
+	candidates, err := b.PruneCandidates(ctx, client, namespaces...)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []ObjectKey = []ObjectKey{}
+	var propagation metav1.DeletionPropagation = metav1.DeletePropagationBackground
+	for i := len(candidates) - 1; i >= 0; i-- {
+		var key ObjectKey = candidates[i]
+		resource, err := resourceClientFor(client, key.GroupVersionKind, key.Namespace)
+		if err != nil {
+			return deleted, err
+		}
+		err = resource.delete(ctx, key.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return deleted, fmt.Errorf("failed to prune %s: %w", key, err)
+		}
+		LogInfo("Pruned object", "Object", key.String())
+		deleted = append(deleted, key)
+	}
+	return deleted, nil
+}
+
+func kindRank(gvk schema.GroupVersionKind) int {
+	for i, kind := range bundleKindOrder {
+		if kind == gvk {
+			return i
+		}
+	}
+	return len(bundleKindOrder)
+}
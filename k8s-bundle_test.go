@@ -0,0 +1,80 @@
+package negotools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBundleOrderAndLabels(t *testing.T) {
+	bundle := NewBundle("spam")
+	app := GenerateApp(testAppConfig())
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "eggs"}}
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "spam", Namespace: "eggs"}}
+	assert.NoError(t, bundle.Add(app.Objects()...))
+	assert.NoError(t, bundle.Add(serviceAccount, namespace))
+
+	var kinds []string = []string{}
+	for _, key := range bundle.Keys() {
+		kinds = append(kinds, key.GroupVersionKind.Kind)
+	}
+	assert.Equal(t, []string{
+		"Namespace", "ServiceAccount", "Secret", "ConfigMap", "Service", "Deployment",
+		"HorizontalPodAutoscaler", "PodDisruptionBudget", "Ingress",
+	}, kinds)
+
+	objects := bundle.Objects()
+	assert.Equal(t, "spam", objects[0].(*corev1.Namespace).Labels[InventoryLabel])
+	assert.Equal(t, "spam", objects[0].(*corev1.Namespace).Annotations[InventoryAnnotation])
+	assert.Empty(t, namespace.Labels, "added objects must not be modified")
+
+	assert.Error(t, bundle.Add(serviceAccount), "duplicates must be rejected")
+	assert.Error(t, bundle.Add(&corev1.Pod{}), "unsupported kinds must be rejected")
+}
+
+func TestBundleInventoryLabelHash(t *testing.T) {
+	bundle := NewBundle("eggs/spam with spaces")
+	assert.Equal(t, "negotools.deepshore.de/inventory=inventory-cc034ae1", bundle.Selector().String())
+}
+
+func TestBundleApplyAndPrune(t *testing.T) {
+	old := NewBundle("spam")
+	oldConfigMap := GenerateConfigMap("spam-legacy", "eggs", map[string]string{"a": "b"})
+	oldSecret := GenerateSecret("spam-secret", "eggs", map[string]string{"c": "d"})
+	assert.NoError(t, old.Add(&oldConfigMap, &oldSecret))
+	other := NewBundle("ham")
+	otherConfigMap := GenerateConfigMap("ham", "eggs", map[string]string{"e": "f"})
+	assert.NoError(t, other.Add(&otherConfigMap))
+
+	client := fake.NewClientset(append(old.Objects(), other.Objects()...)...)
+
+	bundle := NewBundle("spam")
+	assert.NoError(t, bundle.Add(GenerateApp(testAppConfig()).Objects()...))
+	results, err := bundle.Apply(context.Background(), client, ApplyOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, results, 7)
+
+	candidates, err := bundle.PruneCandidates(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ConfigMap/eggs/spam-legacy"}, keyStrings(candidates))
+
+	deleted, err := bundle.Prune(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ConfigMap/eggs/spam-legacy"}, keyStrings(deleted))
+	_, err = client.CoreV1().ConfigMaps("eggs").Get(context.Background(), "spam-legacy", metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = client.CoreV1().ConfigMaps("eggs").Get(context.Background(), "ham", metav1.GetOptions{})
+	assert.NoError(t, err, "objects of other inventories must be kept")
+}
+
+func keyStrings(keys []ObjectKey) []string {
+	var result []string = []string{}
+	for _, key := range keys {
+		result = append(result, key.String())
+	}
+	return result
+}
@@ -0,0 +1,183 @@
+package negotools
+
+// semantic comparison of generated (desired) objects with live objects
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// metadata fields maintained by the API server
+var serverManagedMetadata []string = []string{
+	"managedFields", "resourceVersion", "uid", "generation",
+	"creationTimestamp", "deletionTimestamp", "deletionGracePeriodSeconds", "selfLink",
+}
+
+// path segments below which string values are compared as quantities
+var quantityPathSegments map[string]bool = map[string]bool{
+	"limits": true, "requests": true, "hard": true, "capacity": true, "sizeLimit": true,
+}
+
+// FieldDifference is a single field in which a desired and a live object
+// differ. Desired or Live are nil if the field is missing on that side.
+type FieldDifference struct {
+	Path    string
+	Desired interface{}
+	Live    interface{}
+}
+
+func (d FieldDifference) String() string {
+	return fmt.Sprintf("%s: desired %s, live %s", d.Path, FormatDiffValue(d.Desired), FormatDiffValue(d.Live))
+}
+
+// FormatDiffValue formats a Desired or Live value of a FieldDifference:
+// strings are quoted and missing values are shown as <unset>.
+func FormatDiffValue(value interface{}) string {
+	if value == nil {
+		return "<unset>"
+	}
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// SemanticDiff compares a desired object (as produced by the generators)
+// with the live object from the cluster and returns the differing fields.
+// The desired object is defaulted like the API server would do it, server
+// managed fields (status, managedFields, resourceVersion, ...) are ignored
+// and resource quantities are compared by value ("1" equals "1000m").
+// Fields only set on the live object (e.g. by admission controllers) are
+// not reported, except for additional list items.
+func SemanticDiff(desired, live runtime.Object) ([]FieldDifference, error) {
+	desired = desired.DeepCopyObject()
+	SetDefaults(desired)
+	desiredContent, err := normalizedContent(desired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert desired object: %w", err)
+	}
+	liveContent, err := normalizedContent(live)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert live object: %w", err)
+	}
+
+	var differences []FieldDifference = []FieldDifference{}
+	compareValues("", desiredContent, liveContent, &differences)
+	return differences, nil
+}
+
+// SemanticallyEqual reports whether SemanticDiff finds no differences.
+func SemanticallyEqual(desired, live runtime.Object) (bool, error) {
+	differences, err := SemanticDiff(desired, live)
+	return len(differences) == 0, err
+}
+
+func normalizedContent(obj runtime.Object) (map[string]interface{}, error) {
+	// the API server converts stringData into data
+	if secret, ok := obj.(*corev1.Secret); ok && len(secret.StringData) > 0 {
+		secret = secret.DeepCopy()
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		for key, value := range secret.StringData {
+			secret.Data[key] = []byte(value)
+		}
+		secret.StringData = nil
+		obj = secret
+	}
+
+	var content map[string]interface{}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		content = runtime.DeepCopyJSON(u.Object)
+	} else {
+		var err error
+		content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverManagedMetadata {
+			delete(metadata, field)
+		}
+	}
+	return content, nil
+}
+
+func compareValues(path string, desired, live interface{}, differences *[]FieldDifference) {
+	switch desiredValue := desired.(type) {
+	case nil:
+		return
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			if len(desiredValue) > 0 {
+				*differences = append(*differences, FieldDifference{Path: path, Desired: desired, Live: live})
+			}
+			return
+		}
+		var keys []string = make([]string, 0, len(desiredValue))
+		for key := range desiredValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			compareValues(joinPath(path, key), desiredValue[key], liveValue[key], differences)
+		}
+	case []interface{}:
+		liveValue, _ := live.([]interface{})
+		if len(desiredValue) != len(liveValue) {
+			if len(desiredValue) > 0 || len(liveValue) > 0 {
+				*differences = append(*differences, FieldDifference{Path: path, Desired: desired, Live: live})
+			}
+			return
+		}
+		for i := range desiredValue {
+			compareValues(fmt.Sprintf("%s[%d]", path, i), desiredValue[i], liveValue[i], differences)
+		}
+	default:
+		if reflect.DeepEqual(desired, live) || equalQuantities(path, desired, live) {
+			return
+		}
+		*differences = append(*differences, FieldDifference{Path: path, Desired: desired, Live: live})
+	}
+}
+
+func equalQuantities(path string, desired, live interface{}) bool {
+	var isQuantityPath bool = false
+	for _, segment := range strings.Split(path, ".") {
+		if quantityPathSegments[segment] {
+			isQuantityPath = true
+			break
+		}
+	}
+	if !isQuantityPath {
+		return false
+	}
+	desiredQuantity, err := resource.ParseQuantity(fmt.Sprint(desired))
+	if err != nil {
+		return false
+	}
+	liveQuantity, err := resource.ParseQuantity(fmt.Sprint(live))
+	if err != nil {
+		return false
+	}
+	return desiredQuantity.Cmp(liveQuantity) == 0
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,132 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testDeploymentConfig() DeploymentConfig {
+	return DeploymentConfig{
+		Name:            "spam",
+		Namespace:       "eggs",
+		ContainerName:   "spam",
+		Image:           "registry.example.com/spam:1.2.3",
+		PortName:        "http",
+		ContainerPort:   8080,
+		Replicas:        2,
+		PodLabels:       map[string]string{"app": "spam"},
+		MatchLabels:     map[string]string{"app": "spam"},
+		CpuRequestMilli: 250,
+		CpuLimitMilli:   1000,
+		MemoryRequestMi: 128,
+		MemoryLimitMi:   512,
+		LivenessProbeSpec: ProbeSpec{
+			HttpGetPath: "/healthz",
+			HttpGetPort: 8080,
+		},
+		ReadinessProbeSpec: ProbeSpec{
+			HttpGetPath: "/ready",
+			HttpGetPort: 8080,
+		},
+	}
+}
+
+// simulates what the API server returns for a created deployment
+func liveCopy(deployment appsv1.Deployment) *appsv1.Deployment {
+	live := deployment.DeepCopy()
+	SetDefaults(live)
+	live.ResourceVersion = "4711"
+	live.UID = "b5a6c8e2-0d5e-4a7c-9a36-3f9a1c6b2d10"
+	live.Generation = 3
+	live.CreationTimestamp = metav1.Now()
+	live.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "kubectl"}}
+	live.Annotations = map[string]string{"deployment.kubernetes.io/revision": "3"}
+	live.Status = appsv1.DeploymentStatus{Replicas: 2, ReadyReplicas: 2}
+	return live
+}
+
+func TestSemanticDiffDefaultsAndServerFields(t *testing.T) {
+	desired := GenerateDeployment(testDeploymentConfig())
+	live := liveCopy(desired)
+	// the API server returns the canonical form, the comparison must not care
+	for name, quantity := range live.Spec.Template.Spec.Containers[0].Resources.Limits {
+		if quantity.MilliValue() == 1000 {
+			live.Spec.Template.Spec.Containers[0].Resources.Limits[name] = resource.MustParse("1000m")
+		}
+	}
+
+	differences, err := SemanticDiff(&desired, live)
+	assert.NoError(t, err)
+	assert.Empty(t, differences)
+
+	equal, err := SemanticallyEqual(&desired, live)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+}
+
+func TestSemanticDiffAutoscaledReplicas(t *testing.T) {
+	desired := *GenerateApp(testAppConfig()).Deployment
+	live := liveCopy(desired)
+	// the API server defaults the replicas, the autoscaler scales them
+	var replicas int32 = 4
+	live.Spec.Replicas = &replicas
+
+	differences, err := SemanticDiff(&desired, live)
+	assert.NoError(t, err)
+	assert.Empty(t, differences)
+	equal, err := SemanticallyEqual(&desired, live)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+	assert.Nil(t, desired.Spec.Replicas)
+}
+
+func TestSemanticDiffReportsChanges(t *testing.T) {
+	desired := GenerateDeployment(testDeploymentConfig())
+	live := liveCopy(desired)
+	live.Spec.Template.Spec.Containers[0].Image = "registry.example.com/spam:1.2.2"
+	live.Spec.Template.Spec.DNSPolicy = corev1.DNSDefault
+
+	differences, err := SemanticDiff(&desired, live)
+	assert.NoError(t, err)
+	assert.Len(t, differences, 2)
+	assert.Equal(t, "spec.template.spec.containers[0].image", differences[0].Path)
+	assert.Equal(t, "registry.example.com/spam:1.2.3", differences[0].Desired)
+	assert.Equal(t, "registry.example.com/spam:1.2.2", differences[0].Live)
+	assert.Equal(t, "spec.template.spec.dnsPolicy", differences[1].Path)
+	assert.Equal(t,
+		`spec.template.spec.containers[0].image: desired "registry.example.com/spam:1.2.3", live "registry.example.com/spam:1.2.2"`,
+		differences[0].String())
+	assert.Equal(t, "<unset>", FormatDiffValue(nil))
+	assert.Equal(t, "3", FormatDiffValue(int64(3)))
+}
+
+func TestSemanticDiffSecretStringData(t *testing.T) {
+	desired := GenerateSecret("spam", "eggs", map[string]string{"password": "ham"})
+	live := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "spam", Namespace: "eggs", ResourceVersion: "1"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte("ham")},
+	}
+	differences, err := SemanticDiff(&desired, live)
+	assert.NoError(t, err)
+	assert.Empty(t, differences)
+
+	live.Data["password"] = []byte("bacon")
+	differences, err = SemanticDiff(&desired, live)
+	assert.NoError(t, err)
+	assert.Len(t, differences, 1)
+	assert.Equal(t, "data.password", differences[0].Path)
+}
+
+func TestDefaultPullPolicy(t *testing.T) {
+	assert.Equal(t, corev1.PullAlways, defaultPullPolicy("nginx"))
+	assert.Equal(t, corev1.PullAlways, defaultPullPolicy("nginx:latest"))
+	assert.Equal(t, corev1.PullAlways, defaultPullPolicy("localhost:5000/nginx"))
+	assert.Equal(t, corev1.PullIfNotPresent, defaultPullPolicy("localhost:5000/nginx:1.27"))
+	assert.Equal(t, corev1.PullIfNotPresent, defaultPullPolicy("nginx@sha256:0123"))
+}
@@ -0,0 +1,415 @@
+package negotools
+
+// export of a DeploymentConfig as docker compose service for local development
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ComposeFile is the subset of the compose specification the exporter uses.
+type ComposeFile struct {
+	Services map[string]ComposeService `yaml:"services"`
+	Volumes  map[string]ComposeVolume  `yaml:"volumes,omitempty"`
+	Configs  map[string]ComposeConfig  `yaml:"configs,omitempty"`
+}
+
+type ComposeService struct {
+	Image       string                 `yaml:"image"`
+	PullPolicy  string                 `yaml:"pull_policy,omitempty"`
+	Restart     string                 `yaml:"restart,omitempty"`
+	Environment map[string]string      `yaml:"environment,omitempty"`
+	Ports       []string               `yaml:"ports,omitempty"`
+	Volumes     []ComposeServiceVolume `yaml:"volumes,omitempty"`
+	Configs     []ComposeServiceConfig `yaml:"configs,omitempty"`
+	Healthcheck *ComposeHealthcheck    `yaml:"healthcheck,omitempty"`
+	Deploy      *ComposeDeploy         `yaml:"deploy,omitempty"`
+}
+
+type ComposeServiceVolume struct {
+	// volume, bind or tmpfs
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source,omitempty"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only,omitempty"`
+}
+
+type ComposeServiceConfig struct {
+	Source string `yaml:"source"`
+	Target string `yaml:"target"`
+}
+
+type ComposeVolume struct{}
+
+type ComposeConfig struct {
+	Content string `yaml:"content"`
+}
+
+type ComposeHealthcheck struct {
+	Test        []string `yaml:"test"`
+	Interval    string   `yaml:"interval,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty"`
+	Retries     int32    `yaml:"retries,omitempty"`
+	StartPeriod string   `yaml:"start_period,omitempty"`
+}
+
+type ComposeDeploy struct {
+	Resources ComposeResources `yaml:"resources"`
+}
+
+type ComposeResources struct {
+	Limits       *ComposeResourceValues `yaml:"limits,omitempty"`
+	Reservations *ComposeResourceValues `yaml:"reservations,omitempty"`
+}
+
+type ComposeResourceValues struct {
+	Cpus   string `yaml:"cpus,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+}
+
+// YAML returns the content of a compose.yaml.
+func (f ComposeFile) YAML() ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(f); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+var composePullPolicies map[corev1.PullPolicy]string = map[corev1.PullPolicy]string{
+	corev1.PullAlways:       "always",
+	corev1.PullIfNotPresent: "missing",
+	corev1.PullNever:        "never",
+}
+
+var composeRestartPolicies map[corev1.RestartPolicy]string = map[corev1.RestartPolicy]string{
+	corev1.RestartPolicyAlways:    "always",
+	corev1.RestartPolicyOnFailure: "on-failure",
+	corev1.RestartPolicyNever:     "no",
+}
+
+// ExportCompose translates the Deployment GenerateDeployment produces for
+// the config into a compose service named like the Deployment: image, env
+// (including the data of the ConfigMaps and Secrets referenced by envFrom),
+// ports, volumes, the readiness (or liveness) probe as healthcheck and the
+// resources as deploy.resources. ConfigMap and Secret volumes become compose
+// configs. "$" in values is escaped, compose would interpolate it.
+// Everything that cannot be translated is returned as warning.
+func ExportCompose(config DeploymentConfig, configMaps []corev1.ConfigMap, secrets []corev1.Secret) (ComposeFile, []string) {
+	deployment := GenerateDeployment(config)
+	SetDefaults(&deployment)
+	var podSpec corev1.PodSpec = deployment.Spec.Template.Spec
+	var container corev1.Container = podSpec.Containers[0]
+	var e *composeExporter = &composeExporter{
+		file: ComposeFile{
+			Services: map[string]ComposeService{},
+			Volumes:  map[string]ComposeVolume{},
+			Configs:  map[string]ComposeConfig{},
+		},
+		configMaps: map[string]corev1.ConfigMap{},
+		secrets:    map[string]corev1.Secret{},
+		warnings:   []string{},
+	}
+	for _, configMap := range configMaps {
+		e.configMaps[configMap.Name] = configMap
+	}
+	for _, secret := range secrets {
+		e.secrets[secret.Name] = secret
+	}
+
+	var service ComposeService = ComposeService{
+		Image:       composeEscape(container.Image),
+		PullPolicy:  composePullPolicies[container.ImagePullPolicy],
+		Restart:     composeRestartPolicies[podSpec.RestartPolicy],
+		Environment: e.environment(container),
+	}
+	for _, port := range container.Ports {
+		if port.ContainerPort != 0 {
+			service.Ports = append(service.Ports, fmt.Sprintf("%d:%d", port.ContainerPort, port.ContainerPort))
+		}
+	}
+	e.volumes(podSpec.Volumes, container.VolumeMounts, &service)
+	service.Healthcheck = e.healthcheck(container)
+	service.Deploy = e.deploy(container.Resources)
+
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas > 1 {
+		e.warn("spec.replicas", "compose runs a single instance")
+	}
+	for _, secret := range podSpec.ImagePullSecrets {
+		if secret.Name != "" {
+			e.warn("spec.template.spec.imagePullSecrets", "log in to the registry with docker login instead")
+		}
+	}
+	e.file.Services[deployment.Name] = service
+	sort.Strings(e.warnings)
+	return e.file, e.warnings
+}
+
+type composeExporter struct {
+	file       ComposeFile
+	configMaps map[string]corev1.ConfigMap
+	secrets    map[string]corev1.Secret
+	warnings   []string
+}
+
+func (e *composeExporter) warn(path, format string, args ...interface{}) {
+	e.warnings = append(e.warnings, path+": "+fmt.Sprintf(format, args...))
+}
+
+// env like the kubelet builds it: envFrom sources in order, env on top;
+// keys that are no valid variable names are skipped
+func (e *composeExporter) environment(container corev1.Container) map[string]string {
+	var environment map[string]string = map[string]string{}
+	for i, source := range container.EnvFrom {
+		var sourcePath string = fmt.Sprintf("spec.template.spec.containers[0].envFrom[%d]", i)
+		var data map[string]string
+		switch {
+		case source.ConfigMapRef != nil:
+			configMap, ok := e.configMaps[source.ConfigMapRef.Name]
+			if !ok {
+				e.warn(sourcePath, "ConfigMap %q not given", source.ConfigMapRef.Name)
+				continue
+			}
+			data = configMap.Data
+			if len(configMap.BinaryData) > 0 {
+				e.warn(sourcePath, "binary data of ConfigMap %q is not used for env", configMap.Name)
+			}
+		case source.SecretRef != nil:
+			secret, ok := e.secrets[source.SecretRef.Name]
+			if !ok {
+				e.warn(sourcePath, "Secret %q not given", source.SecretRef.Name)
+				continue
+			}
+			data = secretStringData(secret)
+		}
+		for key, value := range data {
+			if len(validation.IsEnvVarName(source.Prefix+key)) > 0 {
+				e.warn(sourcePath, "key %q is no valid variable name", key)
+				continue
+			}
+			environment[source.Prefix+key] = composeEscape(value)
+		}
+	}
+	for _, env := range container.Env {
+		if env.ValueFrom != nil {
+			e.warn("spec.template.spec.containers[0].env", "valueFrom of %q is not supported", env.Name)
+			continue
+		}
+		environment[env.Name] = composeEscape(env.Value)
+	}
+	return environment
+}
+
+// data and stringData of a Secret, stringData wins like on the API server
+func secretStringData(secret corev1.Secret) map[string]string {
+	var data map[string]string = map[string]string{}
+	for key, value := range secret.Data {
+		data[key] = string(value)
+	}
+	for key, value := range secret.StringData {
+		data[key] = value
+	}
+	return data
+}
+
+func (e *composeExporter) volumes(volumes []corev1.Volume, mounts []corev1.VolumeMount, service *ComposeService) {
+	var byName map[string]corev1.Volume = map[string]corev1.Volume{}
+	for _, volume := range volumes {
+		byName[volume.Name] = volume
+	}
+	for i, mount := range mounts {
+		var mountPath string = fmt.Sprintf("spec.template.spec.containers[0].volumeMounts[%d]", i)
+		volume, ok := byName[mount.Name]
+		if !ok {
+			e.warn(mountPath, "volume %q does not exist", mount.Name)
+			continue
+		}
+		if mount.SubPath != "" && volume.ConfigMap == nil && volume.Secret == nil {
+			e.warn(mountPath, "subPath is only supported for ConfigMap and Secret volumes")
+			continue
+		}
+		switch {
+		case volume.EmptyDir != nil:
+			var volumeType string = "volume"
+			if volume.EmptyDir.Medium == corev1.StorageMediumMemory {
+				volumeType = "tmpfs"
+			}
+			service.Volumes = append(service.Volumes, ComposeServiceVolume{Type: volumeType, Target: composeEscape(mount.MountPath)})
+		case volume.PersistentVolumeClaim != nil:
+			var name string = volume.PersistentVolumeClaim.ClaimName
+			e.file.Volumes[name] = ComposeVolume{}
+			service.Volumes = append(service.Volumes, ComposeServiceVolume{
+				Type: "volume", Source: name, Target: composeEscape(mount.MountPath), ReadOnly: mount.ReadOnly,
+			})
+		case volume.HostPath != nil:
+			service.Volumes = append(service.Volumes, ComposeServiceVolume{
+				Type: "bind", Source: composeEscape(volume.HostPath.Path), Target: composeEscape(mount.MountPath),
+				ReadOnly: mount.ReadOnly,
+			})
+		case volume.ConfigMap != nil:
+			configMap, ok := e.configMaps[volume.ConfigMap.Name]
+			if !ok {
+				e.warn(mountPath, "ConfigMap %q not given", volume.ConfigMap.Name)
+				continue
+			}
+			for key := range configMap.BinaryData {
+				e.warn(mountPath, "binary key %q of ConfigMap %q is not supported", key, configMap.Name)
+			}
+			e.configFiles(volume.Name, configMap.Data, volume.ConfigMap.Items, mount, service)
+		case volume.Secret != nil:
+			secret, ok := e.secrets[volume.Secret.SecretName]
+			if !ok {
+				e.warn(mountPath, "Secret %q not given", volume.Secret.SecretName)
+				continue
+			}
+			var data map[string]string = secretStringData(secret)
+			for key, value := range data {
+				if !utf8.ValidString(value) {
+					e.warn(mountPath, "binary key %q of Secret %q is not supported", key, secret.Name)
+					delete(data, key)
+				}
+			}
+			e.configFiles(volume.Name, data, volume.Secret.Items, mount, service)
+		default:
+			e.warn(mountPath, "volume type of %q is not supported", volume.Name)
+		}
+	}
+}
+
+// every key becomes a compose config mounted as file below the mount path
+func (e *composeExporter) configFiles(volumeName string, data map[string]string, items []corev1.KeyToPath,
+	mount corev1.VolumeMount, service *ComposeService) {
+	var paths map[string]string = map[string]string{}
+	if len(items) > 0 {
+		for _, item := range items {
+			paths[item.Key] = item.Path
+		}
+	} else {
+		for key := range data {
+			paths[key] = key
+		}
+	}
+	var keys []string = make([]string, 0, len(paths))
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := data[key]
+		if !ok {
+			continue
+		}
+		var target string = path.Join(mount.MountPath, paths[key])
+		if mount.SubPath != "" {
+			if paths[key] != mount.SubPath {
+				continue
+			}
+			target = mount.MountPath
+		}
+		var name string = volumeName + "-" + strings.NewReplacer(".", "-", "_", "-", "/", "-").Replace(paths[key])
+		e.file.Configs[name] = ComposeConfig{Content: composeEscape(value)}
+		service.Configs = append(service.Configs, ComposeServiceConfig{Source: name, Target: composeEscape(target)})
+	}
+}
+
+// compose knows only one healthcheck, which decides when dependent services
+// may start; that matches the readiness probe best
+func (e *composeExporter) healthcheck(container corev1.Container) *ComposeHealthcheck {
+	var probe *corev1.Probe = container.ReadinessProbe
+	var probePath string = "spec.template.spec.containers[0].readinessProbe"
+	if !composeProbeSet(probe) {
+		probe, probePath = container.LivenessProbe, "spec.template.spec.containers[0].livenessProbe"
+	} else if composeProbeSet(container.LivenessProbe) {
+		e.warn("spec.template.spec.containers[0].livenessProbe", "only the readinessProbe is used as healthcheck")
+	}
+	if !composeProbeSet(probe) {
+		return nil
+	}
+	var port int32 = probe.HTTPGet.Port.IntVal
+	if probe.HTTPGet.Port.StrVal != "" {
+		for _, containerPort := range container.Ports {
+			if containerPort.Name == probe.HTTPGet.Port.StrVal {
+				port = containerPort.ContainerPort
+			}
+		}
+	}
+	var scheme string = strings.ToLower(string(probe.HTTPGet.Scheme))
+	if scheme == "" {
+		scheme = "http"
+	}
+	if len(probe.HTTPGet.HTTPHeaders) > 0 {
+		e.warn(probePath, "httpHeaders are not supported")
+	}
+	e.warn(probePath, "the healthcheck needs curl in the image")
+	return &ComposeHealthcheck{
+		Test: []string{"CMD-SHELL", fmt.Sprintf("curl -fsSk %s://localhost:%d%s || exit 1",
+			scheme, port, composeEscape(probe.HTTPGet.Path))},
+		Interval:    composeSeconds(probe.PeriodSeconds),
+		Timeout:     composeSeconds(probe.TimeoutSeconds),
+		Retries:     probe.FailureThreshold,
+		StartPeriod: composeSeconds(probe.InitialDelaySeconds),
+	}
+}
+
+// compose interpolates variables like $VAR in every value, "$$" is a
+// literal "$"
+func composeEscape(value string) string {
+	return strings.ReplaceAll(value, "$", "$$")
+}
+
+func composeProbeSet(probe *corev1.Probe) bool {
+	return probe != nil && probe.HTTPGet != nil && probe.HTTPGet.Path != ""
+}
+
+func composeSeconds(seconds int32) string {
+	if seconds == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+func (e *composeExporter) deploy(resources corev1.ResourceRequirements) *ComposeDeploy {
+	var limits *ComposeResourceValues = composeResourceValues(resources.Limits)
+	var reservations *ComposeResourceValues = composeResourceValues(resources.Requests)
+	if limits == nil && reservations == nil {
+		return nil
+	}
+	return &ComposeDeploy{Resources: ComposeResources{Limits: limits, Reservations: reservations}}
+}
+
+func composeResourceValues(resources corev1.ResourceList) *ComposeResourceValues {
+	var values ComposeResourceValues = ComposeResourceValues{}
+	if cpu, ok := resources[corev1.ResourceCPU]; ok && !cpu.IsZero() {
+		values.Cpus = fmt.Sprintf("%g", float64(cpu.MilliValue())/1000)
+	}
+	if memory, ok := resources[corev1.ResourceMemory]; ok && !memory.IsZero() {
+		values.Memory = composeMemory(memory)
+	}
+	if values == (ComposeResourceValues{}) {
+		return nil
+	}
+	return &values
+}
+
+// docker reads the units as binary (1m = 1MiB)
+func composeMemory(memory resource.Quantity) string {
+	var bytes int64 = memory.Value()
+	if bytes%(1024*1024) == 0 {
+		return fmt.Sprintf("%dm", bytes/(1024*1024))
+	}
+	return fmt.Sprintf("%db", bytes)
+}
@@ -0,0 +1,109 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestExportCompose(t *testing.T) {
+	config := testDeploymentConfig()
+	config.EnvVarData = map[string]string{"LOG_LEVEL": "debug"}
+	config.EnvFromConfigMapNames = []string{"spam-config"}
+	config.EnvFromSecretNames = []string{"spam-secret", "missing"}
+	config.Volumes = []corev1.Volume{
+		{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "spam-data"}}},
+		{Name: "files", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "spam-files"},
+		}}},
+		{Name: "socket", VolumeSource: corev1.VolumeSource{CSI: &corev1.CSIVolumeSource{Driver: "spam"}}},
+	}
+	config.VolumeMounts = []corev1.VolumeMount{
+		{Name: "cache", MountPath: "/cache"},
+		{Name: "data", MountPath: "/data"},
+		{Name: "files", MountPath: "/etc/spam"},
+		{Name: "socket", MountPath: "/run/spam"},
+	}
+
+	configMaps := []corev1.ConfigMap{
+		GenerateConfigMap("spam-config", "eggs", map[string]string{"LOG_LEVEL": "info", "DB_HOST": "db", "1BAD": "x"}),
+		GenerateConfigMap("spam-files", "eggs", map[string]string{"app.yaml": "spam: true\n"}),
+	}
+	secrets := []corev1.Secret{GenerateSecret("spam-secret", "eggs", map[string]string{"DB_PASSWORD": "ham"})}
+
+	file, warnings := ExportCompose(config, configMaps, secrets)
+	service := file.Services["spam"]
+	assert.Equal(t, "registry.example.com/spam:1.2.3", service.Image)
+	assert.Equal(t, "missing", service.PullPolicy)
+	assert.Equal(t, "always", service.Restart)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "debug", "DB_HOST": "db", "DB_PASSWORD": "ham"}, service.Environment)
+	assert.Equal(t, []string{"8080:8080"}, service.Ports)
+	assert.Equal(t, []ComposeServiceVolume{
+		{Type: "volume", Target: "/cache"},
+		{Type: "volume", Source: "spam-data", Target: "/data"},
+	}, service.Volumes)
+	assert.Contains(t, file.Volumes, "spam-data")
+	assert.Equal(t, []ComposeServiceConfig{{Source: "files-app-yaml", Target: "/etc/spam/app.yaml"}}, service.Configs)
+	assert.Equal(t, "spam: true\n", file.Configs["files-app-yaml"].Content)
+
+	assert.Equal(t, &ComposeHealthcheck{
+		Test:     []string{"CMD-SHELL", "curl -fsSk http://localhost:8080/ready || exit 1"},
+		Interval: "10s",
+		Timeout:  "1s",
+		Retries:  3,
+	}, service.Healthcheck)
+	assert.Equal(t, &ComposeResourceValues{Cpus: "1", Memory: "512m"}, service.Deploy.Resources.Limits)
+	assert.Equal(t, &ComposeResourceValues{Cpus: "0.25", Memory: "128m"}, service.Deploy.Resources.Reservations)
+
+	assert.Equal(t, []string{
+		"spec.replicas: compose runs a single instance",
+		`spec.template.spec.containers[0].envFrom[0]: key "1BAD" is no valid variable name`,
+		`spec.template.spec.containers[0].envFrom[2]: Secret "missing" not given`,
+		"spec.template.spec.containers[0].livenessProbe: only the readinessProbe is used as healthcheck",
+		"spec.template.spec.containers[0].readinessProbe: the healthcheck needs curl in the image",
+		`spec.template.spec.containers[0].volumeMounts[3]: volume type of "socket" is not supported`,
+	}, warnings)
+
+	data, err := file.YAML()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "    deploy:\n      resources:\n        limits:\n          cpus: \"1\"\n")
+}
+
+func TestExportComposeMinimal(t *testing.T) {
+	config := testDeploymentConfig()
+	config.Replicas = 3
+	config.ImagePullSecretName = "registry"
+	config.LivenessProbeSpec = ProbeSpec{}
+	config.ReadinessProbeSpec = ProbeSpec{}
+	config.CpuRequestMilli, config.CpuLimitMilli, config.MemoryRequestMi, config.MemoryLimitMi = 0, 0, 0, 0
+
+	file, warnings := ExportCompose(config, nil, nil)
+	service := file.Services["spam"]
+	assert.Nil(t, service.Healthcheck)
+	assert.Nil(t, service.Deploy)
+	assert.Equal(t, []string{
+		"spec.replicas: compose runs a single instance",
+		"spec.template.spec.imagePullSecrets: log in to the registry with docker login instead",
+	}, warnings)
+}
+
+func TestExportComposeEscapesDollar(t *testing.T) {
+	config := testDeploymentConfig()
+	config.EnvVarData = map[string]string{"GREETING": "hello $USER"}
+	config.EnvFromSecretNames = []string{"spam-secret"}
+	config.Volumes = []corev1.Volume{{Name: "secret", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "spam-secret"}}}}
+	config.VolumeMounts = []corev1.VolumeMount{{Name: "secret", MountPath: "/etc/spam"}}
+	secrets := []corev1.Secret{GenerateSecret("spam-secret", "eggs", map[string]string{"DB_PASSWORD": "s3cr$t${HOME}$$"})}
+
+	file, _ := ExportCompose(config, nil, secrets)
+	service := file.Services["spam"]
+	assert.Equal(t, "s3cr$$t$${HOME}$$$$", service.Environment["DB_PASSWORD"])
+	assert.Equal(t, "hello $$USER", service.Environment["GREETING"])
+	assert.Equal(t, "s3cr$$t$${HOME}$$$$", file.Configs["secret-DB-PASSWORD"].Content)
+
+	data, err := file.YAML()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "DB_PASSWORD: s3cr$$t$${HOME}$$$$\n")
+}
@@ -0,0 +1,195 @@
+package negotools
+
+// ConfigMaps built from files, directory trees and fs.FS (e.g. embed.FS)
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// MaxConfigMapSize is the limit the API server enforces for the keys and
+// values of data and binaryData together.
+const MaxConfigMapSize int = corev1.MaxSecretSize
+
+// ConfigMapBuilder collects the content of a ConfigMap from files. Keys are
+// sanitized, content that is no valid UTF-8 is stored in BinaryData.
+type ConfigMapBuilder struct {
+	Name       string
+	Namespace  string
+	data       map[string]string
+	binaryData map[string][]byte
+	// where each key came from, for error messages
+	sources map[string]string
+}
+
+func NewConfigMapBuilder(name, namespace string) *ConfigMapBuilder {
+	return &ConfigMapBuilder{
+		Name:       name,
+		Namespace:  namespace,
+		data:       map[string]string{},
+		binaryData: map[string][]byte{},
+		sources:    map[string]string{},
+	}
+}
+
+// SanitizeConfigMapKey turns a file name or relative path into a valid
+// ConfigMap key: path separators and invalid characters become "_" and
+// leading ".." is removed. It returns "" if nothing usable is left.
+func SanitizeConfigMapKey(name string) string {
+	var builder strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '.' || r == '_' {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteRune('_')
+		}
+	}
+	var key string = builder.String()
+	for strings.HasPrefix(key, "..") {
+		key = key[1:]
+	}
+	if key == "." || len(validation.IsConfigMapKey(key)) > 0 {
+		return ""
+	}
+	return key
+}
+
+// Add stores content under the sanitized key. source names the origin of
+// the content in error messages, e.g. the file path.
+func (b *ConfigMapBuilder) Add(key string, content []byte, source string) error {
+	var sanitized string = SanitizeConfigMapKey(key)
+	if sanitized == "" {
+		return fmt.Errorf("%s: no valid ConfigMap key can be derived from %q", source, key)
+	}
+	if previous, ok := b.sources[sanitized]; ok {
+		return fmt.Errorf("%s: key %q is already used by %s", source, sanitized, previous)
+	}
+	b.sources[sanitized] = source
+	if utf8.Valid(content) {
+		b.data[sanitized] = string(content)
+	} else {
+		b.binaryData[sanitized] = append([]byte{}, content...)
+	}
+	return nil
+}
+
+// AddFile adds a file of the local file system with its base name as key.
+func (b *ConfigMapBuilder) AddFile(filePath string) error {
+	return b.AddFileAs(filepath.Base(filePath), filePath)
+}
+
+// AddFileAs adds a file of the local file system under the given key.
+func (b *ConfigMapBuilder) AddFileAs(key, filePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	return b.Add(key, content, filePath)
+}
+
+// AddDir adds all files below a directory of the local file system, see
+// AddFS.
+func (b *ConfigMapBuilder) AddDir(dir string) error {
+	return b.AddFS(os.DirFS(dir), ".")
+}
+
+// AddFS adds all files below root of fsys, e.g. of an embed.FS. The key of
+// a file is its path relative to root with "/" replaced by "_", so
+// "templates/mail/body.txt" below "templates" becomes "mail_body.txt".
+// Entries starting with ".." (the internals of mounted ConfigMaps) are
+// skipped.
+func (b *ConfigMapBuilder) AddFS(fsys fs.FS, root string) error {
+	return fs.WalkDir(fsys, root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), "..") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		var relative string = filePath
+		if root != "." {
+			relative = strings.TrimPrefix(filePath, path.Clean(root)+"/")
+		}
+		return b.Add(strings.ReplaceAll(relative, "/", "_"), content, filePath)
+	})
+}
+
+// Size returns the size of all keys and values as counted for
+// MaxConfigMapSize.
+func (b *ConfigMapBuilder) Size() int {
+	var size int = 0
+	for key, value := range b.data {
+		size += len(key) + len(value)
+	}
+	for key, value := range b.binaryData {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+// Build generates the ConfigMap with GenerateConfigMap and adds the binary
+// data. It fails if the content exceeds MaxConfigMapSize.
+func (b *ConfigMapBuilder) Build() (corev1.ConfigMap, error) {
+	if size := b.Size(); size > MaxConfigMapSize {
+		return corev1.ConfigMap{}, fmt.Errorf("ConfigMap %q has %s of data, more than the limit of %s; largest keys: %s",
+			b.Name, formatBytes(size), formatBytes(MaxConfigMapSize), strings.Join(b.largestKeys(3), ", "))
+	}
+	configMap := GenerateConfigMap(b.Name, b.Namespace, b.data)
+	if len(b.binaryData) > 0 {
+		configMap.BinaryData = map[string][]byte{}
+		for key, value := range b.binaryData {
+			configMap.BinaryData[key] = value
+		}
+	}
+	return configMap, nil
+}
+
+func (b *ConfigMapBuilder) largestKeys(count int) []string {
+	var sizes map[string]int = map[string]int{}
+	for key, value := range b.data {
+		sizes[key] = len(value)
+	}
+	for key, value := range b.binaryData {
+		sizes[key] = len(value)
+	}
+	var keys []string = make([]string, 0, len(sizes))
+	for key := range sizes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if sizes[keys[i]] != sizes[keys[j]] {
+			return sizes[keys[i]] > sizes[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > count {
+		keys = keys[:count]
+	}
+	for i, key := range keys {
+		keys[i] = fmt.Sprintf("%s (%s)", key, formatBytes(sizes[key]))
+	}
+	return keys
+}
+
+func formatBytes(size int) string {
+	return fmt.Sprintf("%d bytes", size)
+}
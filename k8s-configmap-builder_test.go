@@ -0,0 +1,76 @@
+package negotools
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeConfigMapKey(t *testing.T) {
+	assert.Equal(t, "app.yaml", SanitizeConfigMapKey("app.yaml"))
+	assert.Equal(t, ".env", SanitizeConfigMapKey(".env"))
+	assert.Equal(t, "mail_body_de.txt", SanitizeConfigMapKey("mail/body de.txt"))
+	assert.Equal(t, ".hidden", SanitizeConfigMapKey("..hidden"))
+	assert.Equal(t, "", SanitizeConfigMapKey(".."))
+	assert.Equal(t, "", SanitizeConfigMapKey(strings.Repeat("a", 254)))
+}
+
+func TestConfigMapBuilderFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/app.yaml":        {Data: []byte("spam: true\n")},
+		"templates/mail/body.txt":   {Data: []byte("Hello\n")},
+		"templates/logo.png":        {Data: []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}},
+		"templates/..data/app.yaml": {Data: []byte("ignored")},
+		"other/ignored.txt":         {Data: []byte("ignored")},
+	}
+	builder := NewConfigMapBuilder("spam-templates", "eggs")
+	assert.NoError(t, builder.AddFS(fsys, "templates"))
+	configMap, err := builder.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "spam-templates", configMap.Name)
+	assert.Equal(t, map[string]string{"app.yaml": "spam: true\n", "mail_body.txt": "Hello\n"}, configMap.Data)
+	assert.Equal(t, map[string][]byte{"logo.png": {0x89, 'P', 'N', 'G', 0xff, 0x00}}, configMap.BinaryData)
+	assert.Empty(t, ValidateConfigMapInput(configMap.Name, configMap.Namespace, configMap.Data))
+}
+
+func TestConfigMapBuilderFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("-----BEGIN CERTIFICATE-----\n"), 0o600))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "conf.d"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "conf.d", "a.conf"), []byte("a"), 0o600))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "..2024_01_01"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "..2024_01_01", "ca.crt"), []byte("old"), 0o600))
+
+	builder := NewConfigMapBuilder("spam", "eggs")
+	assert.NoError(t, builder.AddDir(dir))
+	assert.NoError(t, builder.AddFileAs("bundle.crt", filepath.Join(dir, "ca.crt")))
+	configMap, err := builder.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bundle.crt", "ca.crt", "conf.d_a.conf"}, keysOf(configMap.Data))
+
+	err = builder.AddFile(filepath.Join(dir, "ca.crt"))
+	assert.ErrorContains(t, err, `key "ca.crt" is already used by ca.crt`)
+	assert.Error(t, builder.AddFile(filepath.Join(dir, "missing")))
+}
+
+func TestConfigMapBuilderSizeLimit(t *testing.T) {
+	builder := NewConfigMapBuilder("spam", "eggs")
+	assert.NoError(t, builder.Add("big.bin", append([]byte{0xff}, make([]byte, MaxConfigMapSize)...), "big.bin"))
+	assert.NoError(t, builder.Add("small.txt", []byte("spam"), "small.txt"))
+	_, err := builder.Build()
+	assert.EqualError(t, err, `ConfigMap "spam" has 1048597 bytes of data, more than the limit of 1048576 bytes; largest keys: big.bin (1048577 bytes), small.txt (4 bytes)`)
+}
+
+func keysOf(data map[string]string) []string {
+	var keys []string = []string{}
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,262 @@
+package negotools
+
+// encoding of objects into multi-document YAML manifests and decoding of
+// YAML/JSON manifests back into typed objects
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	kjson "sigs.k8s.io/json"
+	"sigs.k8s.io/yaml"
+)
+
+// scheme holding all API groups the generators of this package produce.
+// Kinds outside of it are decoded into unstructured objects.
+var manifestScheme *runtime.Scheme = newManifestScheme()
+
+func newManifestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(scheme))
+	utilruntime.Must(appsv1.AddToScheme(scheme))
+	utilruntime.Must(networking.AddToScheme(scheme))
+	utilruntime.Must(autoscalingv2.AddToScheme(scheme))
+	utilruntime.Must(policyv1.AddToScheme(scheme))
+	return scheme
+}
+
+// ManifestDecodeError describes which document of a manifest stream (and, if
+// known, which field of it) could not be decoded.
+type ManifestDecodeError struct {
+	// zero based index of the document in the stream
+	Document int
+	Kind     string
+	Name     string
+	// JSON path of the offending field, empty if unknown
+	Field string
+	Err   error
+}
+
+func (e *ManifestDecodeError) Error() string {
+	var msg string = fmt.Sprintf("document %d", e.Document)
+	if e.Kind != "" {
+		msg += fmt.Sprintf(" (%s %q)", e.Kind, e.Name)
+	}
+	if e.Field != "" {
+		msg += fmt.Sprintf(": field %q", e.Field)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *ManifestDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeManifests reads a stream of YAML documents (separated by "---") or
+// JSON objects and returns them as typed objects (corev1, appsv1,
+// networkingv1, ...). Kinds unknown to this package are returned as
+// *unstructured.Unstructured. Empty documents are skipped; unknown or
+// duplicate fields are reported as errors.
+func DecodeManifests(r io.Reader) ([]runtime.Object, error) {
+	var objects []runtime.Object = []runtime.Object{}
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(r))
+	var index int = 0
+	for {
+		chunk, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return objects, &ManifestDecodeError{Document: index, Err: err}
+		}
+		// JSON objects need no separator, each one is a document
+		documents, splitErr := splitJSONObjects(chunk)
+		for _, document := range documents {
+			object, err := DecodeManifest(document)
+			if err != nil {
+				var decodeErr *ManifestDecodeError
+				if errors.As(err, &decodeErr) {
+					decodeErr.Document = index
+					return objects, decodeErr
+				}
+				return objects, &ManifestDecodeError{Document: index, Err: err}
+			}
+			if object == nil {
+				LogTrace(fmt.Sprintf("Skipping empty document %d", index))
+			} else {
+				objects = append(objects, object)
+			}
+			index++
+		}
+		if splitErr != nil {
+			return objects, &ManifestDecodeError{Document: index, Err: splitErr}
+		}
+	}
+}
+
+// splitJSONObjects splits concatenated JSON objects; YAML documents,
+// including flow mappings like "{kind: Secret}", are returned as they are.
+// On errors the objects before the invalid one are returned as well.
+func splitJSONObjects(chunk []byte) ([][]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(chunk), []byte("{")) {
+		return [][]byte{chunk}, nil
+	}
+	var documents [][]byte = [][]byte{}
+	decoder := json.NewDecoder(bytes.NewReader(chunk))
+	for {
+		var document json.RawMessage
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil && len(documents) == 0 {
+			return [][]byte{chunk}, nil
+		}
+		if err != nil {
+			return documents, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+// DecodeManifest decodes a single YAML or JSON document. It returns nil for
+// empty documents; content after a JSON object is an error.
+func DecodeManifest(document []byte) (runtime.Object, error) {
+	if documents, err := splitJSONObjects(document); err != nil || len(documents) > 1 {
+		return nil, errors.New("unexpected content after the JSON object, use DecodeManifests for streams")
+	}
+	data, err := yaml.YAMLToJSON(document)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	var content map[string]interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	var u unstructured.Unstructured = unstructured.Unstructured{Object: content}
+	var gvk schema.GroupVersionKind = u.GroupVersionKind()
+	if gvk.Kind == "" {
+		return nil, &ManifestDecodeError{Field: "kind", Err: errors.New("kind is missing")}
+	}
+	if gvk.Version == "" {
+		return nil, &ManifestDecodeError{Kind: gvk.Kind, Name: u.GetName(), Field: "apiVersion", Err: errors.New("apiVersion is missing")}
+	}
+	if !manifestScheme.Recognizes(gvk) {
+		// decoded again, so numbers are int64 instead of float64 as the
+		// unstructured helpers expect
+		var object *unstructured.Unstructured = &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(data); err != nil {
+			return nil, &ManifestDecodeError{Kind: gvk.Kind, Name: u.GetName(), Err: err}
+		}
+		return object, nil
+	}
+
+	object, err := manifestScheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	strictErrs, err := kjson.UnmarshalStrict(data, object)
+	if err != nil {
+		return nil, &ManifestDecodeError{
+			Kind: gvk.Kind, Name: u.GetName(), Field: typeErrorField(data, gvk), Err: err,
+		}
+	}
+	if len(strictErrs) > 0 {
+		var field string
+		var fieldErr kjson.FieldError
+		if errors.As(strictErrs[0], &fieldErr) {
+			field = fieldErr.FieldPath()
+		}
+		return nil, &ManifestDecodeError{
+			Kind: gvk.Kind, Name: u.GetName(), Field: field, Err: errors.Join(strictErrs...),
+		}
+	}
+	return object, nil
+}
+
+// EncodeManifests writes the objects as YAML documents separated by "---".
+// apiVersion and kind are filled in from the object type; status and unset
+// creationTimestamps are omitted.
+func EncodeManifests(w io.Writer, objects []runtime.Object) error {
+	for i, obj := range objects {
+		gvk, err := objectKind(obj)
+		if err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+		var u unstructured.Unstructured = unstructured.Unstructured{Object: content}
+		u.SetGroupVersionKind(gvk)
+		delete(u.Object, "status")
+		removeNullTimestamps(u.Object)
+		data, err := yaml.Marshal(u.Object)
+		if err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unset metav1.Time fields are encoded as null, e.g. the creationTimestamp
+// of pod templates
+func removeNullTimestamps(content map[string]interface{}) {
+	for key, value := range content {
+		switch value := value.(type) {
+		case nil:
+			if key == "creationTimestamp" {
+				delete(content, key)
+			}
+		case map[string]interface{}:
+			removeNullTimestamps(value)
+		case []interface{}:
+			for _, item := range value {
+				if item, ok := item.(map[string]interface{}); ok {
+					removeNullTimestamps(item)
+				}
+			}
+		}
+	}
+}
+
+// the decoder of sigs.k8s.io/json does not expose the path of type errors,
+// so the document is decoded again with encoding/json to find it
+func typeErrorField(data []byte, gvk schema.GroupVersionKind) string {
+	object, err := manifestScheme.New(gvk)
+	if err != nil {
+		return ""
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(json.Unmarshal(data, object), &typeErr) {
+		return typeErr.Field
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package negotools
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testManifests string = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: spam
+  namespace: eggs
+data:
+  key: value
+---
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ham
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: ham
+  template:
+    metadata:
+      labels:
+        app: ham
+    spec:
+      containers:
+      - name: ham
+        image: ham:1.0
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: foo
+`
+
+func TestDecodeManifests(t *testing.T) {
+	objects, err := DecodeManifests(strings.NewReader(testManifests))
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+
+	configMap, ok := objects[0].(*corev1.ConfigMap)
+	assert.True(t, ok)
+	assert.Equal(t, "value", configMap.Data["key"])
+
+	deployment, ok := objects[1].(*appsv1.Deployment)
+	assert.True(t, ok)
+	assert.Equal(t, int32(2), *deployment.Spec.Replicas)
+	assert.Equal(t, "ham:1.0", deployment.Spec.Template.Spec.Containers[0].Image)
+
+	widget, ok := objects[2].(*unstructured.Unstructured)
+	assert.True(t, ok)
+	assert.Equal(t, "Widget", widget.GetKind())
+}
+
+func TestDecodeManifestsJSON(t *testing.T) {
+	const input string = `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "spam"}, "stringData": {"a": "b"}}`
+	objects, err := DecodeManifests(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+	secret, ok := objects[0].(*corev1.Secret)
+	assert.True(t, ok)
+	assert.Equal(t, "b", secret.StringData["a"])
+}
+
+func TestDecodeManifestsJSONStream(t *testing.T) {
+	const input string = `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "spam"}}
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "eggs"}}
+---
+{kind: ConfigMap, apiVersion: v1, metadata: {name: ham}}
+`
+	objects, err := DecodeManifests(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+	assert.Equal(t, "spam", objects[0].(*corev1.Secret).Name)
+	assert.Equal(t, "eggs", objects[1].(*corev1.ConfigMap).Name)
+	assert.Equal(t, "ham", objects[2].(*corev1.ConfigMap).Name)
+
+	_, err = DecodeManifests(strings.NewReader(`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "spam"}} {"kind": `))
+	var decodeErr *ManifestDecodeError
+	assert.ErrorAs(t, err, &decodeErr)
+	assert.Equal(t, 1, decodeErr.Document)
+
+	_, err = DecodeManifest([]byte(`{"apiVersion": "v1", "kind": "Secret"} {"apiVersion": "v1", "kind": "ConfigMap"}`))
+	assert.ErrorContains(t, err, "unexpected content after the JSON object")
+}
+
+func TestDecodeManifestUnstructuredNumbers(t *testing.T) {
+	object, err := DecodeManifest([]byte(`{"apiVersion": "example.com/v1", "kind": "Widget", "spec": {"replicas": 3, "ratio": 0.5}}`))
+	assert.NoError(t, err)
+	widget := object.(*unstructured.Unstructured)
+	replicas, found, err := unstructured.NestedInt64(widget.Object, "spec", "replicas")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(3), replicas)
+	ratio, _, err := unstructured.NestedFloat64(widget.Object, "spec", "ratio")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, ratio)
+}
+
+func TestDecodeManifestsUnknownField(t *testing.T) {
+	const input string = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: spam
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ham
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: ham
+        imag: ham:1.0
+`
+	_, err := DecodeManifests(strings.NewReader(input))
+	var decodeErr *ManifestDecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, 1, decodeErr.Document)
+	assert.Equal(t, "Deployment", decodeErr.Kind)
+	assert.Equal(t, "ham", decodeErr.Name)
+	assert.Equal(t, "spec.template.spec.containers[0].imag", decodeErr.Field)
+}
+
+func TestDecodeManifestsTypeError(t *testing.T) {
+	const input string = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ham
+spec:
+  replicas: "two"
+`
+	_, err := DecodeManifests(strings.NewReader(input))
+	var decodeErr *ManifestDecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, 0, decodeErr.Document)
+	assert.Equal(t, "spec.replicas", decodeErr.Field)
+}
+
+func TestDecodeManifestsMissingKind(t *testing.T) {
+	_, err := DecodeManifests(strings.NewReader("apiVersion: v1\nmetadata:\n  name: spam\n"))
+	var decodeErr *ManifestDecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "kind", decodeErr.Field)
+}
+
+func TestEncodeManifestsRoundTrip(t *testing.T) {
+	bundle := GenerateApp(testAppConfig())
+	var buffer bytes.Buffer
+	assert.NoError(t, EncodeManifests(&buffer, bundle.Objects()))
+	assert.Contains(t, buffer.String(), "apiVersion: apps/v1\nkind: Deployment\n")
+	assert.NotContains(t, buffer.String(), "creationTimestamp")
+	assert.NotContains(t, buffer.String(), "status: {}")
+	assert.Equal(t, 6, strings.Count(buffer.String(), "---\n"))
+
+	objects, err := DecodeManifests(&buffer)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 7)
+	for i, obj := range objects {
+		SetDefaults(obj)
+		equal, err := SemanticallyEqual(bundle.Objects()[i], obj)
+		assert.NoError(t, err)
+		assert.True(t, equal, "%T", obj)
+	}
+	assert.Empty(t, bundle.Deployment.APIVersion, "the encoded objects must not be modified")
+}
@@ -0,0 +1,247 @@
+package negotools
+
+// client side replica of the defaulting the API server applies to the kinds
+// produced by the generators, so desired and live objects can be compared
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	defaultTerminationMessagePath        string = "/dev/termination-log"
+	defaultSchedulerName                 string = "default-scheduler"
+	defaultTerminationGracePeriodSeconds int64  = 30
+	defaultVolumeMode                    int32  = 0644
+	defaultRevisionHistoryLimit          int32  = 10
+	defaultProgressDeadlineSeconds       int32  = 600
+)
+
+// SetDefaults sets the fields the API server would default on creation of
+// the given object. Only kinds produced by this package are handled, all
+// other objects are left untouched. Replicas are not defaulted: without
+// them another controller like a HorizontalPodAutoscaler owns the scale.
+func SetDefaults(obj runtime.Object) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		setDeploymentDefaults(o)
+	case *appsv1.StatefulSet:
+		setStatefulSetDefaults(o)
+	case *corev1.Service:
+		setServiceDefaults(o)
+	case *corev1.Secret:
+		if o.Type == "" {
+			o.Type = corev1.SecretTypeOpaque
+		}
+	case *networking.Ingress:
+		setIngressDefaults(o)
+	case *corev1.Pod:
+		setPodSpecDefaults(&o.Spec)
+	}
+}
+
+func setDeploymentDefaults(deployment *appsv1.Deployment) {
+	var spec *appsv1.DeploymentSpec = &deployment.Spec
+	if spec.Strategy.Type == "" {
+		spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+	}
+	if spec.Strategy.Type == appsv1.RollingUpdateDeploymentStrategyType {
+		if spec.Strategy.RollingUpdate == nil {
+			spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{}
+		}
+		if spec.Strategy.RollingUpdate.MaxUnavailable == nil {
+			spec.Strategy.RollingUpdate.MaxUnavailable = ptrTo(intstr.FromString("25%"))
+		}
+		if spec.Strategy.RollingUpdate.MaxSurge == nil {
+			spec.Strategy.RollingUpdate.MaxSurge = ptrTo(intstr.FromString("25%"))
+		}
+	}
+	if spec.RevisionHistoryLimit == nil {
+		spec.RevisionHistoryLimit = ptrTo(defaultRevisionHistoryLimit)
+	}
+	if spec.ProgressDeadlineSeconds == nil {
+		spec.ProgressDeadlineSeconds = ptrTo(defaultProgressDeadlineSeconds)
+	}
+	setPodSpecDefaults(&spec.Template.Spec)
+}
+
+func setStatefulSetDefaults(statefulSet *appsv1.StatefulSet) {
+	var spec *appsv1.StatefulSetSpec = &statefulSet.Spec
+	if spec.PodManagementPolicy == "" {
+		spec.PodManagementPolicy = appsv1.OrderedReadyPodManagement
+	}
+	if spec.UpdateStrategy.Type == "" {
+		spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
+	}
+	if spec.UpdateStrategy.Type == appsv1.RollingUpdateStatefulSetStrategyType {
+		if spec.UpdateStrategy.RollingUpdate == nil {
+			spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{}
+		}
+		if spec.UpdateStrategy.RollingUpdate.Partition == nil {
+			spec.UpdateStrategy.RollingUpdate.Partition = ptrTo(int32(0))
+		}
+	}
+	if spec.RevisionHistoryLimit == nil {
+		spec.RevisionHistoryLimit = ptrTo(defaultRevisionHistoryLimit)
+	}
+	if spec.PersistentVolumeClaimRetentionPolicy == nil {
+		spec.PersistentVolumeClaimRetentionPolicy = &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{}
+	}
+	if spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted == "" {
+		spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted = appsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	}
+	if spec.PersistentVolumeClaimRetentionPolicy.WhenScaled == "" {
+		spec.PersistentVolumeClaimRetentionPolicy.WhenScaled = appsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	}
+	setPodSpecDefaults(&spec.Template.Spec)
+}
+
+func setServiceDefaults(service *corev1.Service) {
+	var spec *corev1.ServiceSpec = &service.Spec
+	if spec.Type == "" {
+		spec.Type = corev1.ServiceTypeClusterIP
+	}
+	if spec.SessionAffinity == "" {
+		spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+	if spec.Type != corev1.ServiceTypeExternalName && spec.InternalTrafficPolicy == nil {
+		spec.InternalTrafficPolicy = ptrTo(corev1.ServiceInternalTrafficPolicyCluster)
+	}
+	for i := range spec.Ports {
+		var port *corev1.ServicePort = &spec.Ports[i]
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if port.TargetPort == intstr.FromInt32(0) || port.TargetPort == intstr.FromString("") {
+			port.TargetPort = intstr.FromInt32(port.Port)
+		}
+	}
+}
+
+func setIngressDefaults(ingress *networking.Ingress) {
+	for i := range ingress.Spec.Rules {
+		var rule *networking.IngressRule = &ingress.Spec.Rules[i]
+		if rule.HTTP == nil {
+			continue
+		}
+		for j := range rule.HTTP.Paths {
+			if rule.HTTP.Paths[j].PathType == nil {
+				rule.HTTP.Paths[j].PathType = ptrTo(networking.PathTypeImplementationSpecific)
+			}
+		}
+	}
+}
+
+func setPodSpecDefaults(spec *corev1.PodSpec) {
+	if spec.DNSPolicy == "" {
+		spec.DNSPolicy = corev1.DNSClusterFirst
+	}
+	if spec.RestartPolicy == "" {
+		spec.RestartPolicy = corev1.RestartPolicyAlways
+	}
+	if spec.TerminationGracePeriodSeconds == nil {
+		spec.TerminationGracePeriodSeconds = ptrTo(defaultTerminationGracePeriodSeconds)
+	}
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	if spec.SchedulerName == "" {
+		spec.SchedulerName = defaultSchedulerName
+	}
+	if spec.EnableServiceLinks == nil {
+		spec.EnableServiceLinks = ptrTo(corev1.DefaultEnableServiceLinks)
+	}
+	for i := range spec.Volumes {
+		setVolumeDefaults(&spec.Volumes[i])
+	}
+	for i := range spec.InitContainers {
+		setContainerDefaults(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		setContainerDefaults(&spec.Containers[i])
+	}
+}
+
+func setVolumeDefaults(volume *corev1.Volume) {
+	if volume.ConfigMap != nil && volume.ConfigMap.DefaultMode == nil {
+		volume.ConfigMap.DefaultMode = ptrTo(defaultVolumeMode)
+	}
+	if volume.Secret != nil && volume.Secret.DefaultMode == nil {
+		volume.Secret.DefaultMode = ptrTo(defaultVolumeMode)
+	}
+	if volume.Projected != nil && volume.Projected.DefaultMode == nil {
+		volume.Projected.DefaultMode = ptrTo(defaultVolumeMode)
+	}
+	if volume.HostPath != nil && volume.HostPath.Type == nil {
+		volume.HostPath.Type = ptrTo(corev1.HostPathUnset)
+	}
+}
+
+func setContainerDefaults(container *corev1.Container) {
+	if container.TerminationMessagePath == "" {
+		container.TerminationMessagePath = defaultTerminationMessagePath
+	}
+	if container.TerminationMessagePolicy == "" {
+		container.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	}
+	if container.ImagePullPolicy == "" {
+		container.ImagePullPolicy = defaultPullPolicy(container.Image)
+	}
+	for i := range container.Ports {
+		if container.Ports[i].Protocol == "" {
+			container.Ports[i].Protocol = corev1.ProtocolTCP
+		}
+	}
+	for i := range container.Env {
+		var source *corev1.EnvVarSource = container.Env[i].ValueFrom
+		if source != nil && source.FieldRef != nil && source.FieldRef.APIVersion == "" {
+			source.FieldRef.APIVersion = "v1"
+		}
+	}
+	setProbeDefaults(container.LivenessProbe)
+	setProbeDefaults(container.ReadinessProbe)
+	setProbeDefaults(container.StartupProbe)
+}
+
+func setProbeDefaults(probe *corev1.Probe) {
+	if probe == nil {
+		return
+	}
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = 1
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = 10
+	}
+	if probe.SuccessThreshold == 0 {
+		probe.SuccessThreshold = 1
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = 3
+	}
+	if probe.HTTPGet != nil && probe.HTTPGet.Scheme == "" {
+		probe.HTTPGet.Scheme = corev1.URISchemeHTTP
+	}
+}
+
+// images without tag or with tag "latest" are always pulled, see
+// https://kubernetes.io/docs/concepts/containers/images/#imagepullpolicy-defaulting
+func defaultPullPolicy(image string) corev1.PullPolicy {
+	if strings.Contains(image, "@") {
+		return corev1.PullIfNotPresent
+	}
+	var lastSegment string = image[strings.LastIndex(image, "/")+1:]
+	if !strings.Contains(lastSegment, ":") || strings.HasSuffix(lastSegment, ":latest") {
+		return corev1.PullAlways
+	}
+	return corev1.PullIfNotPresent
+}
+
+func ptrTo[T any](value T) *T {
+	return &value
+}
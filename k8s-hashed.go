@@ -0,0 +1,336 @@
+package negotools
+
+// immutable ConfigMaps and Secrets named after a hash of their content
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// name the object had before the hash was appended; a hash of it if it is
+	// no valid label value
+	HashedBaseNameLabel string = "negotools.deepshore.de/base-name"
+	// full name the object had before the hash was appended
+	HashedBaseNameAnnotation string = "negotools.deepshore.de/base-name"
+)
+
+// CRC32 (like CRC32Checksum, formatted like NameHash) of all keys and values
+// in key order, so the hash does not depend on map iteration order
+func contentHash(data map[string][]byte, extra ...string) string {
+	var keys []string = make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := crc32.NewIEEE()
+	for _, value := range extra {
+		fmt.Fprintf(hash, "%d:%s", len(value), value)
+	}
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%d:%s%d:", len(key), key, len(data[key]))
+		hash.Write(data[key])
+	}
+	return fmt.Sprintf("%0*x", nameHashLength, hash.Sum32())
+}
+
+// HashConfigMap returns a copy of the ConfigMap, e.g. from
+// GenerateConfigMap, that is immutable and named "<name>-<content hash>"
+// (kustomize style). Changed content results in a new object, so pods
+// referencing it are rolled and rollbacks find the old content. Apply the
+// new name to Deployments with DeploymentConfig.WithHashedNames.
+func HashConfigMap(configMap corev1.ConfigMap) corev1.ConfigMap {
+	var hashed corev1.ConfigMap = *configMap.DeepCopy()
+	var data map[string][]byte = map[string][]byte{}
+	for key, value := range hashed.Data {
+		data[key] = []byte(value)
+	}
+	// keys are unique across data and binaryData
+	for key, value := range hashed.BinaryData {
+		data[key] = value
+	}
+	setHashedName(&hashed.ObjectMeta, contentHash(data))
+	hashed.Immutable = ptrTo(true)
+	return hashed
+}
+
+// HashSecret is HashConfigMap for Secrets; Data and StringData are hashed as
+// the API server merges them.
+func HashSecret(secret corev1.Secret) corev1.Secret {
+	var hashed corev1.Secret = *secret.DeepCopy()
+	var data map[string][]byte = map[string][]byte{}
+	for key, value := range hashed.Data {
+		data[key] = value
+	}
+	for key, value := range hashed.StringData {
+		data[key] = []byte(value)
+	}
+	setHashedName(&hashed.ObjectMeta, contentHash(data, string(hashed.Type)))
+	hashed.Immutable = ptrTo(true)
+	return hashed
+}
+
+func setHashedName(meta *metav1.ObjectMeta, hash string) {
+	var base string = meta.Name
+	if meta.Labels == nil {
+		meta.Labels = map[string]string{}
+	}
+	meta.Labels[HashedBaseNameLabel] = hashedBaseNameLabelValue(base)
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[HashedBaseNameAnnotation] = base
+	if len(base)+len(hash)+1 > validation.DNS1123SubdomainMaxLength {
+		base = SanitizeDNS1123Subdomain(base[:validation.DNS1123SubdomainMaxLength-len(hash)-1])
+	}
+	meta.Name = base + "-" + hash
+}
+
+func hashedBaseNameLabelValue(base string) string {
+	if len(validation.IsValidLabelValue(base)) == 0 {
+		return base
+	}
+	return NameHash(base)
+}
+
+// WithHashedNames returns a copy of the config with all references to the
+// base names of the hashed ConfigMaps and Secrets (envFrom, volumes, image
+// pull secret) replaced by their hashed names.
+func (config DeploymentConfig) WithHashedNames(configMaps []corev1.ConfigMap, secrets []corev1.Secret) DeploymentConfig {
+	var configMapNames map[string]string = map[string]string{}
+	for _, configMap := range configMaps {
+		if base, ok := configMap.Annotations[HashedBaseNameAnnotation]; ok {
+			configMapNames[base] = configMap.Name
+		}
+	}
+	var secretNames map[string]string = map[string]string{}
+	for _, secret := range secrets {
+		if base, ok := secret.Annotations[HashedBaseNameAnnotation]; ok {
+			secretNames[base] = secret.Name
+		}
+	}
+	rename := func(names map[string]string, name string) string {
+		if hashed, ok := names[name]; ok {
+			return hashed
+		}
+		return name
+	}
+
+	config.EnvFromConfigMapNames = append([]string{}, config.EnvFromConfigMapNames...)
+	for i, name := range config.EnvFromConfigMapNames {
+		config.EnvFromConfigMapNames[i] = rename(configMapNames, name)
+	}
+	config.EnvFromSecretNames = append([]string{}, config.EnvFromSecretNames...)
+	for i, name := range config.EnvFromSecretNames {
+		config.EnvFromSecretNames[i] = rename(secretNames, name)
+	}
+	config.ImagePullSecretName = rename(secretNames, config.ImagePullSecretName)
+
+	var volumes []corev1.Volume = []corev1.Volume{}
+	for _, volume := range config.Volumes {
+		volume = *volume.DeepCopy()
+		if volume.ConfigMap != nil {
+			volume.ConfigMap.Name = rename(configMapNames, volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			volume.Secret.SecretName = rename(secretNames, volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					source.ConfigMap.Name = rename(configMapNames, source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					source.Secret.Name = rename(secretNames, source.Secret.Name)
+				}
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+	if config.Volumes != nil {
+		config.Volumes = volumes
+	}
+	return config
+}
+
+// StaleHashedGenerations lists the hashed ConfigMaps and Secrets in the
+// namespace that share a base name with the current ones but are neither
+// current nor referenced by any Pod, ReplicaSet, Deployment, StatefulSet or
+// DaemonSet. Generations of old ReplicaSets are kept, so rollbacks work
+// within the revision history of the Deployment.
+func StaleHashedGenerations(
+	ctx context.Context, client kubernetes.Interface, namespace string,
+	currentConfigMaps []corev1.ConfigMap, currentSecrets []corev1.Secret,
+) ([]ObjectKey, error) {
+	referencedConfigMaps, referencedSecrets, err := podSpecReferencesInNamespace(ctx, client, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var stale []ObjectKey = []ObjectKey{}
+
+	var configMapBases, configMapNames sets.Set[string] = sets.New[string](), sets.New[string]()
+	for _, configMap := range currentConfigMaps {
+		if base, ok := configMap.Labels[HashedBaseNameLabel]; ok {
+			configMapBases.Insert(base)
+			configMapNames.Insert(configMap.Name)
+		}
+	}
+	if configMapBases.Len() > 0 {
+		listOptions, err := hashedGenerationsListOptions(configMapBases)
+		if err != nil {
+			return nil, err
+		}
+		list, err := client.CoreV1().ConfigMaps(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ConfigMaps: %w", err)
+		}
+		for _, configMap := range list.Items {
+			if !configMapNames.Has(configMap.Name) && !referencedConfigMaps.Has(configMap.Name) {
+				stale = append(stale, ObjectKey{
+					GroupVersionKind: corev1.SchemeGroupVersion.WithKind("ConfigMap"), Namespace: namespace, Name: configMap.Name,
+				})
+			}
+		}
+	}
+
+	var secretBases, secretNames sets.Set[string] = sets.New[string](), sets.New[string]()
+	for _, secret := range currentSecrets {
+		if base, ok := secret.Labels[HashedBaseNameLabel]; ok {
+			secretBases.Insert(base)
+			secretNames.Insert(secret.Name)
+		}
+	}
+	if secretBases.Len() > 0 {
+		listOptions, err := hashedGenerationsListOptions(secretBases)
+		if err != nil {
+			return nil, err
+		}
+		list, err := client.CoreV1().Secrets(namespace).List(ctx, listOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Secrets: %w", err)
+		}
+		for _, secret := range list.Items {
+			if !secretNames.Has(secret.Name) && !referencedSecrets.Has(secret.Name) {
+				stale = append(stale, ObjectKey{
+					GroupVersionKind: corev1.SchemeGroupVersion.WithKind("Secret"), Namespace: namespace, Name: secret.Name,
+				})
+			}
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].String() < stale[j].String() })
+	return stale, nil
+}
+
+func hashedGenerationsListOptions(bases sets.Set[string]) (metav1.ListOptions, error) {
+	requirement, err := labels.NewRequirement(HashedBaseNameLabel, selection.In, sets.List(bases))
+	if err != nil {
+		return metav1.ListOptions{}, err
+	}
+	return metav1.ListOptions{LabelSelector: labels.NewSelector().Add(*requirement).String()}, nil
+}
+
+// names of the ConfigMaps and Secrets the pod specs of all workloads in the
+// namespace reference
+func podSpecReferencesInNamespace(ctx context.Context, client kubernetes.Interface, namespace string) (sets.Set[string], sets.Set[string], error) {
+	var specs []corev1.PodSpec = []corev1.PodSpec{}
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list Pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		specs = append(specs, pod.Spec)
+	}
+	replicaSets, err := client.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list ReplicaSets: %w", err)
+	}
+	for _, replicaSet := range replicaSets.Items {
+		specs = append(specs, replicaSet.Spec.Template.Spec)
+	}
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list Deployments: %w", err)
+	}
+	for _, deployment := range deployments.Items {
+		specs = append(specs, deployment.Spec.Template.Spec)
+	}
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list StatefulSets: %w", err)
+	}
+	for _, statefulSet := range statefulSets.Items {
+		specs = append(specs, statefulSet.Spec.Template.Spec)
+	}
+	daemonSets, err := client.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list DaemonSets: %w", err)
+	}
+	for _, daemonSet := range daemonSets.Items {
+		specs = append(specs, daemonSet.Spec.Template.Spec)
+	}
+
+	var configMaps, secrets sets.Set[string] = sets.New[string](), sets.New[string]()
+	for _, spec := range specs {
+		addPodSpecReferences(spec, configMaps, secrets)
+	}
+	return configMaps, secrets, nil
+}
+
+func addPodSpecReferences(spec corev1.PodSpec, configMaps, secrets sets.Set[string]) {
+	for _, secret := range spec.ImagePullSecrets {
+		secrets.Insert(secret.Name)
+	}
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			configMaps.Insert(volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			secrets.Insert(volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMaps.Insert(source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					secrets.Insert(source.Secret.Name)
+				}
+			}
+		}
+	}
+	var containers []corev1.Container = append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range spec.EphemeralContainers {
+		containers = append(containers, corev1.Container{Env: container.Env, EnvFrom: container.EnvFrom})
+	}
+	for _, container := range containers {
+		for _, source := range container.EnvFrom {
+			if source.ConfigMapRef != nil {
+				configMaps.Insert(source.ConfigMapRef.Name)
+			}
+			if source.SecretRef != nil {
+				secrets.Insert(source.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps.Insert(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets.Insert(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package negotools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHashConfigMap(t *testing.T) {
+	original := GenerateConfigMap("spam-config", "eggs", map[string]string{"a": "1", "b": "2"})
+	hashed := HashConfigMap(original)
+	assert.Equal(t, "spam-config", original.Name, "the original must not be modified")
+	assert.Regexp(t, `^spam-config-[0-9a-f]{8}$`, hashed.Name)
+	assert.True(t, *hashed.Immutable)
+	assert.Equal(t, "spam-config", hashed.Labels[HashedBaseNameLabel])
+	assert.Equal(t, "spam-config", hashed.Annotations[HashedBaseNameAnnotation])
+
+	assert.Equal(t, hashed.Name, HashConfigMap(original).Name, "hashes must be stable")
+	changed := GenerateConfigMap("spam-config", "eggs", map[string]string{"a": "1", "b": "3"})
+	assert.NotEqual(t, hashed.Name, HashConfigMap(changed).Name)
+	// no ambiguity between keys and values
+	assert.NotEqual(t,
+		HashConfigMap(GenerateConfigMap("spam", "eggs", map[string]string{"ab": "c"})).Name,
+		HashConfigMap(GenerateConfigMap("spam", "eggs", map[string]string{"a": "bc"})).Name)
+
+	long := HashConfigMap(GenerateConfigMap(strings.Repeat("spam.", 51), "eggs", nil))
+	assert.LessOrEqual(t, len(long.Name), 253)
+	assert.Equal(t, NameHash(strings.Repeat("spam.", 51)), long.Labels[HashedBaseNameLabel])
+}
+
+func TestHashSecret(t *testing.T) {
+	secret := GenerateSecret("spam-secret", "eggs", map[string]string{"password": "ham"})
+	hashed := HashSecret(secret)
+	assert.Regexp(t, `^spam-secret-[0-9a-f]{8}$`, hashed.Name)
+	assert.True(t, *hashed.Immutable)
+
+	// stringData and data with the same content are the same Secret
+	secret.StringData = nil
+	secret.Data = map[string][]byte{"password": []byte("ham")}
+	assert.Equal(t, hashed.Name, HashSecret(secret).Name)
+	secret.Type = corev1.SecretTypeBasicAuth
+	assert.NotEqual(t, hashed.Name, HashSecret(secret).Name)
+}
+
+func TestDeploymentConfigWithHashedNames(t *testing.T) {
+	configMap := HashConfigMap(GenerateConfigMap("spam-config", "eggs", map[string]string{"a": "1"}))
+	secret := HashSecret(GenerateSecret("spam-secret", "eggs", map[string]string{"b": "2"}))
+	config := testDeploymentConfig()
+	config.EnvFromConfigMapNames = []string{"spam-config", "other"}
+	config.EnvFromSecretNames = []string{"spam-secret"}
+	config.Volumes = []corev1.Volume{
+		{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "spam-config"},
+		}}},
+		{Name: "all", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+			Sources: []corev1.VolumeProjection{{Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "spam-secret"},
+			}}},
+		}}},
+	}
+
+	hashed := config.WithHashedNames([]corev1.ConfigMap{configMap}, []corev1.Secret{secret})
+	assert.Equal(t, []string{configMap.Name, "other"}, hashed.EnvFromConfigMapNames)
+	assert.Equal(t, []string{secret.Name}, hashed.EnvFromSecretNames)
+	assert.Equal(t, configMap.Name, hashed.Volumes[0].ConfigMap.Name)
+	assert.Equal(t, secret.Name, hashed.Volumes[1].Projected.Sources[0].Secret.Name)
+
+	assert.Equal(t, []string{"spam-config", "other"}, config.EnvFromConfigMapNames, "the original must not be modified")
+	assert.Equal(t, "spam-secret", config.Volumes[1].Projected.Sources[0].Secret.Name)
+}
+
+func TestStaleHashedGenerations(t *testing.T) {
+	generation := func(value string) corev1.ConfigMap {
+		return HashConfigMap(GenerateConfigMap("spam-config", "eggs", map[string]string{"a": value}))
+	}
+	oldest, old, current := generation("1"), generation("2"), generation("3")
+	other := HashConfigMap(GenerateConfigMap("ham-config", "eggs", map[string]string{"a": "1"}))
+	oldSecret := HashSecret(GenerateSecret("spam-secret", "eggs", map[string]string{"b": "1"}))
+	currentSecret := HashSecret(GenerateSecret("spam-secret", "eggs", map[string]string{"b": "2"}))
+
+	// the ReplicaSet of the previous revision still uses the old generation
+	config := testDeploymentConfig()
+	config.EnvFromConfigMapNames = []string{old.Name}
+	previous := GenerateDeployment(config)
+	replicaSet := &appsv1.ReplicaSet{}
+	replicaSet.Name, replicaSet.Namespace = "spam-1", "eggs"
+	replicaSet.Spec.Template = previous.Spec.Template
+
+	client := fake.NewClientset([]runtime.Object{&oldest, &old, &current, &other, &oldSecret, &currentSecret, replicaSet}...)
+	stale, err := StaleHashedGenerations(context.Background(), client, "eggs",
+		[]corev1.ConfigMap{current}, []corev1.Secret{currentSecret})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ConfigMap/eggs/" + oldest.Name, "Secret/eggs/" + oldSecret.Name}, keyStrings(stale))
+
+	stale, err = StaleHashedGenerations(context.Background(), client, "eggs", nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, stale)
+}
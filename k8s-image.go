@@ -0,0 +1,150 @@
+package negotools
+
+// parsing, normalization and registry rewriting of container image references
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	DefaultImageRegistry   string = "docker.io"
+	defaultImageRepository string = "library"
+)
+
+var (
+	imageRegistryRegexp   *regexp.Regexp = regexp.MustCompile(`^(localhost|[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*)(:[0-9]+)?$`)
+	imageRepositoryRegexp *regexp.Regexp = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+	imageTagRegexp        *regexp.Regexp = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+	imageDigestRegexp     *regexp.Regexp = regexp.MustCompile(`^[a-z0-9]+([+._-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`)
+)
+
+// ImageReference is a parsed container image reference like
+// "registry.example.com:5000/team/app:1.2.3@sha256:...".
+type ImageReference struct {
+	// registry host (with optional port), DefaultImageRegistry if omitted
+	Registry string
+	// repository path, "library/" is prepended for official Docker Hub images
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageReference parses and normalizes an image reference the way
+// container runtimes do: a missing registry means Docker Hub and single
+// component Docker Hub repositories live below "library/". A missing tag is
+// not replaced by "latest".
+func ParseImageReference(image string) (ImageReference, error) {
+	var ref ImageReference = ImageReference{}
+	if image == "" {
+		return ref, fmt.Errorf("image reference is empty")
+	}
+	var remainder string = image
+	if at := strings.Index(remainder, "@"); at >= 0 {
+		ref.Digest = remainder[at+1:]
+		remainder = remainder[:at]
+		if !imageDigestRegexp.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest %q in image reference %q", ref.Digest, image)
+		}
+	}
+	if colon := strings.LastIndex(remainder, ":"); colon > strings.LastIndex(remainder, "/") {
+		ref.Tag = remainder[colon+1:]
+		remainder = remainder[:colon]
+		if !imageTagRegexp.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("invalid tag %q in image reference %q", ref.Tag, image)
+		}
+	}
+
+	var firstSlash int = strings.Index(remainder, "/")
+	if firstSlash >= 0 && isRegistryHost(remainder[:firstSlash]) {
+		ref.Registry = remainder[:firstSlash]
+		ref.Repository = remainder[firstSlash+1:]
+	} else {
+		ref.Registry = DefaultImageRegistry
+		ref.Repository = remainder
+	}
+	if ref.Registry == DefaultImageRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = defaultImageRepository + "/" + ref.Repository
+	}
+	if !imageRegistryRegexp.MatchString(ref.Registry) {
+		return ref, fmt.Errorf("invalid registry %q in image reference %q", ref.Registry, image)
+	}
+	if !imageRepositoryRegexp.MatchString(ref.Repository) {
+		return ref, fmt.Errorf("invalid repository %q in image reference %q", ref.Repository, image)
+	}
+	return ref, nil
+}
+
+// the first path component is a registry if it looks like a host name
+func isRegistryHost(component string) bool {
+	return component == "localhost" || strings.ContainsAny(component, ".:")
+}
+
+// Name returns registry and repository without tag and digest.
+func (ref ImageReference) Name() string {
+	return ref.Registry + "/" + ref.Repository
+}
+
+// String returns the fully qualified reference.
+func (ref ImageReference) String() string {
+	var image string = ref.Name()
+	if ref.Tag != "" {
+		image += ":" + ref.Tag
+	}
+	if ref.Digest != "" {
+		image += "@" + ref.Digest
+	}
+	return image
+}
+
+// IsPinned reports whether the reference contains a digest.
+func (ref ImageReference) IsPinned() bool {
+	return ref.Digest != ""
+}
+
+// ImageRewriteRule replaces the Prefix of an image name (registry and
+// repository, e.g. "docker.io" or "docker.io/library") by Replacement, for
+// example to pull from an internal mirror in air-gapped clusters. Prefixes
+// only match complete path components.
+type ImageRewriteRule struct {
+	Prefix      string
+	Replacement string
+}
+
+// RewriteImage applies the first matching rule to the image and returns the
+// fully qualified result. Images not matched by any rule are returned
+// normalized but otherwise unchanged.
+func RewriteImage(image string, rules []ImageRewriteRule) (string, error) {
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return image, err
+	}
+	var name string = ref.Name()
+	for _, rule := range rules {
+		var prefix string = strings.TrimSuffix(rule.Prefix, "/")
+		if name != prefix && !strings.HasPrefix(name, prefix+"/") {
+			continue
+		}
+		rewritten, err := ParseImageReference(strings.TrimSuffix(rule.Replacement, "/") + name[len(prefix):])
+		if err != nil {
+			return image, fmt.Errorf("rewrite rule %q -> %q: %w", rule.Prefix, rule.Replacement, err)
+		}
+		rewritten.Tag = ref.Tag
+		rewritten.Digest = ref.Digest
+		LogTrace(fmt.Sprintf("Rewrote image %q to %q", image, rewritten.String()))
+		return rewritten.String(), nil
+	}
+	return ref.String(), nil
+}
+
+// GenerateDeployment cannot return errors, invalid images are left as they
+// are and reported by DeploymentConfig.Validate
+func rewriteContainerImage(image string, rules []ImageRewriteRule) string {
+	rewritten, err := RewriteImage(image, rules)
+	if err != nil {
+		LogWarning(fmt.Sprintf("Failed to rewrite image %q", image), err)
+		return image
+	}
+	return rewritten
+}
@@ -0,0 +1,81 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDigest string = "sha256:0f0e7a4a2d6e2b1f6cd8b1d2c9b0ad2a7a5f0f3b9c6c3e3d1f4b8e9a7c6d5e4f"
+
+func TestParseImageReference(t *testing.T) {
+	var cases map[string]ImageReference = map[string]ImageReference{
+		"nginx":                      {Registry: "docker.io", Repository: "library/nginx"},
+		"nginx:1.27":                 {Registry: "docker.io", Repository: "library/nginx", Tag: "1.27"},
+		"bitnami/redis:7.2":          {Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.2"},
+		"localhost:5000/spam":        {Registry: "localhost:5000", Repository: "spam"},
+		"ghcr.io/deepshore/spam:v1":  {Registry: "ghcr.io", Repository: "deepshore/spam", Tag: "v1"},
+		"quay.io/eggs@" + testDigest: {Registry: "quay.io", Repository: "eggs", Digest: testDigest},
+		"quay.io/eggs:1@" + testDigest: {
+			Registry: "quay.io", Repository: "eggs", Tag: "1", Digest: testDigest,
+		},
+	}
+	for image, expected := range cases {
+		actual, err := ParseImageReference(image)
+		assert.NoError(t, err, image)
+		assert.Equal(t, expected, actual, image)
+	}
+
+	for _, image := range []string{"", "Nginx", "nginx:", "nginx@sha256:xyz", "ghcr.io/spam:tag with space", "-bad/spam"} {
+		_, err := ParseImageReference(image)
+		assert.Error(t, err, image)
+	}
+}
+
+func TestImageReferenceString(t *testing.T) {
+	ref, err := ParseImageReference("nginx:1.27@" + testDigest)
+	assert.NoError(t, err)
+	assert.Equal(t, "docker.io/library/nginx:1.27@"+testDigest, ref.String())
+	assert.Equal(t, "docker.io/library/nginx", ref.Name())
+	assert.True(t, ref.IsPinned())
+}
+
+func TestRewriteImage(t *testing.T) {
+	var rules []ImageRewriteRule = []ImageRewriteRule{
+		{Prefix: "docker.io/library", Replacement: "mirror.internal/official"},
+		{Prefix: "docker.io", Replacement: "mirror.internal/dockerhub"},
+		{Prefix: "ghcr.io/deepshore/", Replacement: "mirror.internal/deepshore/"},
+	}
+	var cases map[string]string = map[string]string{
+		"nginx:1.27":                     "mirror.internal/official/nginx:1.27",
+		"bitnami/redis@" + testDigest:    "mirror.internal/dockerhub/bitnami/redis@" + testDigest,
+		"ghcr.io/deepshore/spam:v1":      "mirror.internal/deepshore/spam:v1",
+		"ghcr.io/deepshore-labs/spam:v1": "ghcr.io/deepshore-labs/spam:v1",
+		"registry.example.com/eggs:1.0":  "registry.example.com/eggs:1.0",
+	}
+	for image, expected := range cases {
+		actual, err := RewriteImage(image, rules)
+		assert.NoError(t, err, image)
+		assert.Equal(t, expected, actual, image)
+	}
+}
+
+func TestGenerateDeploymentRewritesImages(t *testing.T) {
+	config := testDeploymentConfig()
+	config.Image = "spam:1.0"
+	config.ImageRewriteRules = []ImageRewriteRule{{Prefix: "docker.io", Replacement: "mirror.internal"}}
+	deployment := GenerateDeployment(config)
+	assert.Equal(t, "mirror.internal/library/spam:1.0", deployment.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestDeploymentConfigValidateImage(t *testing.T) {
+	config := testDeploymentConfig()
+	config.RequireImageDigest = true
+	assert.Equal(t, []string{"Image"}, fieldErrorPaths(t, config.Validate()))
+
+	config.Image = "registry.example.com/spam@" + testDigest
+	assert.NoError(t, config.Validate())
+
+	config.Image = "Registry.example.com/Spam"
+	assert.Equal(t, []string{"Image"}, fieldErrorPaths(t, config.Validate()))
+}
@@ -0,0 +1,95 @@
+package negotools
+
+// DNS-1123 safe object names built from arbitrary input
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// suffixes of names derived from an app name with DerivedName
+const (
+	ConfigMapNameSuffix string = "config"
+	SecretNameSuffix    string = "secret"
+	TLSSecretNameSuffix string = "tls"
+	ServiceNameSuffix   string = "svc"
+)
+
+const (
+	nameHashLength int = 8
+	// length derived names cut their base to, so all names derived from the
+	// same base share it as long as the suffix is not longer than 15
+	// characters
+	derivedNameBaseLength int = validation.DNS1123LabelMaxLength - 16
+)
+
+// NameHash returns the 8 character hash suffix names get when they are
+// truncated: the lowercase, zero padded CRC32Checksum of the input.
+func NameHash(input string) string {
+	return fmt.Sprintf("%0*s", nameHashLength, strings.ToLower(CRC32Checksum(input)))
+}
+
+// SanitizeDNS1123Label turns arbitrary input into a valid DNS-1123 label
+// (e.g. the name of a Service or a namespace): it is lowercased, invalid
+// characters become "-" and leading and trailing dashes are dropped. Names
+// longer than 63 characters are truncated and get NameHash of the input
+// appended, so different long inputs stay unique.
+func SanitizeDNS1123Label(input string) string {
+	return truncateName(input, sanitizeLabel(input), validation.DNS1123LabelMaxLength)
+}
+
+// SanitizeDNS1123Subdomain works like SanitizeDNS1123Label but keeps dots
+// and allows 253 characters (e.g. the name of a ConfigMap or Secret).
+func SanitizeDNS1123Subdomain(input string) string {
+	var labels []string = []string{}
+	for _, label := range strings.Split(input, ".") {
+		if label = sanitizeLabel(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return truncateName(input, strings.Join(labels, "."), validation.DNS1123SubdomainMaxLength)
+}
+
+// DerivedName builds the name of an object belonging to base, e.g.
+// DerivedName("spam", ServiceNameSuffix) = "spam-svc". The result is a valid
+// DNS-1123 label; bases longer than 47 characters are truncated to the
+// same prefix for all suffixes, so related objects keep recognizable,
+// consistent names.
+func DerivedName(base, suffix string) string {
+	var sanitized string = sanitizeLabel(base)
+	if len(sanitized) > derivedNameBaseLength {
+		sanitized = truncateName(base, sanitized, derivedNameBaseLength)
+	}
+	var name string = strings.Trim(sanitized+"-"+sanitizeLabel(suffix), "-")
+	return truncateName(base+"-"+suffix, name, validation.DNS1123LabelMaxLength)
+}
+
+func sanitizeLabel(input string) string {
+	var builder strings.Builder
+	var lastDash bool = false
+	for _, r := range strings.ToLower(input) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			builder.WriteRune(r)
+			lastDash = false
+		} else if !lastDash {
+			builder.WriteRune('-')
+			lastDash = true
+		}
+	}
+	return strings.Trim(builder.String(), "-")
+}
+
+// cuts a sanitized name to maxLength including the hash of the original
+// input; names without any usable character consist of the hash only
+func truncateName(input, sanitized string, maxLength int) string {
+	if sanitized == "" {
+		return NameHash(input)
+	}
+	if len(sanitized) <= maxLength {
+		return sanitized
+	}
+	var prefix string = strings.TrimRight(sanitized[:maxLength-nameHashLength-1], "-.")
+	return prefix + "-" + NameHash(input)
+}
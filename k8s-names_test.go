@@ -0,0 +1,72 @@
+package negotools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestNameHash(t *testing.T) {
+	assert.Equal(t, "43daff3d", NameHash("spam"))
+	assert.Equal(t, "00000000", NameHash(""))
+	assert.Len(t, NameHash("eggs"), 8)
+}
+
+func TestSanitizeDNS1123Label(t *testing.T) {
+	assert.Equal(t, "spam", SanitizeDNS1123Label("spam"))
+	assert.Equal(t, "spam-eggs", SanitizeDNS1123Label("  Spam & Eggs__"))
+	assert.Equal(t, "my-app-v1-2", SanitizeDNS1123Label("-my.app/v1.2-"))
+	assert.Equal(t, "sp-m", SanitizeDNS1123Label("späm"))
+	assert.Equal(t, NameHash("___"), SanitizeDNS1123Label("___"))
+
+	long := strings.Repeat("spam-", 20)
+	name := SanitizeDNS1123Label(long)
+	assert.Len(t, name, 63)
+	assert.True(t, strings.HasSuffix(name, "-"+NameHash(long)))
+	assert.Empty(t, validation.IsDNS1123Label(name))
+	assert.NotEqual(t, name, SanitizeDNS1123Label(long+"x"))
+	assert.Equal(t, name, SanitizeDNS1123Label(long), "names must be stable")
+}
+
+func TestSanitizeDNS1123Subdomain(t *testing.T) {
+	assert.Equal(t, "spam.eggs.example.com", SanitizeDNS1123Subdomain("Spam.Eggs..example.com."))
+	assert.Equal(t, "spam-config.v1", SanitizeDNS1123Subdomain("spam_config.-v1-"))
+
+	long := strings.Repeat("spam.", 60)
+	name := SanitizeDNS1123Subdomain(long)
+	assert.LessOrEqual(t, len(name), 253)
+	assert.True(t, strings.HasSuffix(name, "-"+NameHash(long)))
+	assert.Empty(t, validation.IsDNS1123Subdomain(name))
+}
+
+func TestDerivedName(t *testing.T) {
+	assert.Equal(t, "spam-svc", DerivedName("spam", ServiceNameSuffix))
+	assert.Equal(t, "spam-eggs-tls", DerivedName("Spam Eggs", TLSSecretNameSuffix))
+
+	long := "a-very-long-application-name-from-a-custom-resource-with-a-namespace"
+	service, tls, config := DerivedName(long, ServiceNameSuffix), DerivedName(long, TLSSecretNameSuffix), DerivedName(long, ConfigMapNameSuffix)
+	for _, name := range []string{service, tls, config} {
+		assert.Empty(t, validation.IsDNS1123Label(name))
+	}
+	base := strings.TrimSuffix(service, "-svc")
+	assert.True(t, strings.HasSuffix(base, NameHash(long)))
+	assert.Equal(t, base+"-tls", tls)
+	assert.Equal(t, base+"-config", config)
+
+	// short suffixes would leave room for the whole base, it is cut anyway
+	medium := strings.Repeat("spam-eggs-", 5) + "ham-spam"
+	assert.Len(t, medium, 58)
+	base = strings.TrimSuffix(DerivedName(medium, ServiceNameSuffix), "-svc")
+	assert.Len(t, base, 47)
+	assert.True(t, strings.HasSuffix(base, NameHash(medium)))
+	for _, suffix := range []string{ConfigMapNameSuffix, SecretNameSuffix, TLSSecretNameSuffix, ReleaseTrackCandidate, "canary"} {
+		assert.Equal(t, base+"-"+suffix, DerivedName(medium, suffix))
+	}
+	assert.Equal(t, strings.Repeat("spam-eggs-", 4)+"spam-svc", DerivedName(strings.Repeat("spam-eggs-", 4)+"spam", ServiceNameSuffix))
+
+	longSuffix := DerivedName(long, strings.Repeat("suffix", 10))
+	assert.Len(t, longSuffix, 63)
+	assert.Empty(t, validation.IsDNS1123Label(longSuffix))
+}
@@ -0,0 +1,255 @@
+package negotools
+
+// user supplied overrides merged into generated objects
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	kjson "sigs.k8s.io/json"
+	"sigs.k8s.io/yaml"
+)
+
+type OverrideType string
+
+const (
+	// strategic merge patch as used by kubectl: lists like containers, env,
+	// ports or volumes are merged by their key (e.g. name)
+	StrategicMergeOverride OverrideType = "strategic"
+	// JSON merge patch (RFC 7386): maps are merged, lists are replaced
+	MergePatchOverride OverrideType = "merge"
+)
+
+// OverrideOptions control which parts of an object an override may change.
+type OverrideOptions struct {
+	// defaults to StrategicMergeOverride for typed objects and to
+	// MergePatchOverride for unstructured ones
+	Type OverrideType
+	// paths the override may set, including everything below them, e.g.
+	// "metadata.annotations" or "spec.template.spec.containers[*].resources".
+	// "[*]" stands for the elements of a list merged by key: adding, deleting
+	// or replacing elements needs permission for "...[*]" itself, changing
+	// fields of existing elements only for the fields. Overrides touching any
+	// other path are rejected, so an empty list rejects every override.
+	AllowedPaths []string
+}
+
+// ApplyOverride merges a user supplied override (JSON or YAML) into the
+// object in place, e.g. a PodSpec fragment of a custom resource into a
+// generated Deployment. All paths of the override are checked against
+// options.AllowedPaths first; forbidden paths are returned as aggregate of
+// field errors and leave the object unchanged. Fields unknown to typed
+// objects are rejected as well.
+func ApplyOverride(obj runtime.Object, override []byte, options OverrideOptions) error {
+	patchJSON, err := yaml.YAMLToJSON(override)
+	if err != nil {
+		return fmt.Errorf("failed to parse override: %w", err)
+	}
+	var patch interface{}
+	if err := json.Unmarshal(patchJSON, &patch); err != nil {
+		return fmt.Errorf("failed to parse override: %w", err)
+	}
+	if patch == nil {
+		return nil
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return errors.New("override must be an object")
+	}
+
+	_, isUnstructured := obj.(*unstructured.Unstructured)
+	var overrideType OverrideType = options.Type
+	if overrideType == "" {
+		overrideType = StrategicMergeOverride
+		if isUnstructured {
+			overrideType = MergePatchOverride
+		}
+	}
+	if overrideType != StrategicMergeOverride && overrideType != MergePatchOverride {
+		return fmt.Errorf("unsupported override type %q", overrideType)
+	}
+	if overrideType == StrategicMergeOverride && isUnstructured {
+		return errors.New("strategic merge overrides need a typed object")
+	}
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var touched sets.Set[string] = sets.New[string]()
+	if overrideType == StrategicMergeOverride {
+		var originalContent interface{}
+		if err := json.Unmarshal(original, &originalContent); err != nil {
+			return err
+		}
+		patchMeta, err := strategicpatch.NewPatchMetaFromStruct(obj)
+		if err != nil {
+			return err
+		}
+		collectStrategicOverridePaths("", patch, originalContent, patchMeta, touched)
+	} else {
+		collectOverridePaths("", patch, touched)
+	}
+	if err := checkOverridePaths(touched, options.AllowedPaths).ToAggregate(); err != nil {
+		return err
+	}
+	var merged []byte
+	if overrideType == StrategicMergeOverride {
+		merged, err = strategicpatch.StrategicMergePatch(original, patchJSON, obj)
+	} else {
+		merged, err = jsonpatch.MergePatch(original, patchJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply override: %w", err)
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		var content map[string]interface{}
+		if err := json.Unmarshal(merged, &content); err != nil {
+			return err
+		}
+		u.Object = content
+		return nil
+	}
+	result := reflect.New(reflect.TypeOf(obj).Elem())
+	strictErrs, err := kjson.UnmarshalStrict(merged, result.Interface())
+	if err != nil {
+		return fmt.Errorf("failed to apply override: %w", err)
+	}
+	if len(strictErrs) > 0 {
+		return fmt.Errorf("invalid override: %w", errors.Join(strictErrs...))
+	}
+	reflect.ValueOf(obj).Elem().Set(result.Elem())
+	return nil
+}
+
+func checkOverridePaths(touched sets.Set[string], allowedPaths []string) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	for _, path := range sets.List(touched) {
+		if !overridePathAllowed(path, allowedPaths) {
+			errs = append(errs, field.Forbidden(field.NewPath(path), "may not be overridden"))
+		}
+	}
+	return errs
+}
+
+// collects the paths a JSON merge patch sets; lists are replaced as a whole
+func collectOverridePaths(path string, value interface{}, touched sets.Set[string]) {
+	content, ok := value.(map[string]interface{})
+	if !ok {
+		touched.Insert(path)
+		return
+	}
+	if len(content) == 0 && path != "" {
+		touched.Insert(path)
+	}
+	for key, item := range content {
+		collectOverridePaths(joinPath(path, key), item, touched)
+	}
+}
+
+// collects the paths a strategic merge patch sets. Directives count for the
+// field they refer to. Elements of lists merged by key are looked up in the
+// original object: changing fields of an existing element needs permission
+// for the fields, any element whose key does not exist yet (or that is
+// deleted or replaced) needs permission for the elements ("[*]") as well.
+func collectStrategicOverridePaths(
+	path string, value interface{}, original interface{}, patchMeta strategicpatch.LookupPatchMeta, touched sets.Set[string],
+) {
+	content, ok := value.(map[string]interface{})
+	if !ok {
+		touched.Insert(path)
+		return
+	}
+	if len(content) == 0 && path != "" {
+		touched.Insert(path)
+	}
+	originalContent, _ := original.(map[string]interface{})
+	for key, item := range content {
+		switch {
+		case key == "$patch" || key == "$retainKeys":
+			touched.Insert(path)
+			continue
+		case strings.HasPrefix(key, "$setElementOrder/") || strings.HasPrefix(key, "$deleteFromPrimitiveList/"):
+			touched.Insert(joinPath(path, key[strings.Index(key, "/")+1:]))
+			continue
+		}
+		var itemPath string = joinPath(path, key)
+		switch item := item.(type) {
+		case map[string]interface{}:
+			var itemMeta strategicpatch.LookupPatchMeta
+			if patchMeta != nil {
+				itemMeta, _, _ = patchMeta.LookupPatchMetadataForStruct(key)
+			}
+			collectStrategicOverridePaths(itemPath, item, originalContent[key], itemMeta, touched)
+		case []interface{}:
+			var mergeKey string
+			var elementMeta strategicpatch.LookupPatchMeta
+			if patchMeta != nil {
+				if lookedUp, sliceMeta, err := patchMeta.LookupPatchMetadataForSlice(key); err == nil {
+					elementMeta, mergeKey = lookedUp, sliceMeta.GetPatchMergeKey()
+				}
+			}
+			if mergeKey == "" || !allMaps(item) {
+				touched.Insert(itemPath)
+				continue
+			}
+			originalElements, _ := originalContent[key].([]interface{})
+			for _, element := range item {
+				var fields map[string]interface{} = element.(map[string]interface{})
+				originalElement, found := findMergeElement(originalElements, mergeKey, fields[mergeKey])
+				if !found {
+					touched.Insert(itemPath + "[*]")
+				}
+				var rest map[string]interface{} = map[string]interface{}{}
+				for field, fieldValue := range fields {
+					if field != mergeKey {
+						rest[field] = fieldValue
+					}
+				}
+				if len(rest) > 0 {
+					collectStrategicOverridePaths(itemPath+"[*]", rest, originalElement, elementMeta, touched)
+				}
+			}
+		default:
+			touched.Insert(itemPath)
+		}
+	}
+}
+
+func findMergeElement(elements []interface{}, mergeKey string, keyValue interface{}) (interface{}, bool) {
+	if keyValue == nil {
+		return nil, false
+	}
+	for _, element := range elements {
+		if fields, ok := element.(map[string]interface{}); ok && reflect.DeepEqual(fields[mergeKey], keyValue) {
+			return element, true
+		}
+	}
+	return nil, false
+}
+
+func allMaps(items []interface{}) bool {
+	for _, item := range items {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return len(items) > 0
+}
+
+func overridePathAllowed(path string, allowedPaths []string) bool {
+	for _, allowed := range allowedPaths {
+		if path == allowed || strings.HasPrefix(path, allowed+".") || strings.HasPrefix(path, allowed+"[") {
+			return true
+		}
+	}
+	return false
+}
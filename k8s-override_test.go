@@ -0,0 +1,149 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var testOverridePaths []string = []string{
+	"metadata.annotations",
+	"spec.template.spec.nodeSelector",
+	"spec.template.spec.tolerations",
+	"spec.template.spec.containers[*].resources",
+	"spec.template.spec.containers[*].env",
+}
+
+func TestApplyOverrideStrategic(t *testing.T) {
+	deployment := GenerateDeployment(testDeploymentConfig())
+	override := `
+metadata:
+  annotations:
+    team: python
+spec:
+  template:
+    spec:
+      nodeSelector:
+        disk: ssd
+      containers:
+        - name: spam
+          resources:
+            limits:
+              memory: 1Gi
+          env:
+            - name: DEBUG
+              value: "1"
+`
+	err := ApplyOverride(&deployment, []byte(override), OverrideOptions{AllowedPaths: testOverridePaths})
+	assert.NoError(t, err)
+	assert.Equal(t, "python", deployment.Annotations["team"])
+	assert.Equal(t, "ssd", deployment.Spec.Template.Spec.NodeSelector["disk"])
+
+	var containers []corev1.Container = deployment.Spec.Template.Spec.Containers
+	assert.Len(t, containers, 1)
+	assert.Equal(t, "registry.example.com/spam:1.2.3", containers[0].Image)
+	assert.Equal(t, resource.MustParse("1Gi"), containers[0].Resources.Limits[corev1.ResourceMemory])
+	assert.Equal(t, resource.MustParse("1"), containers[0].Resources.Limits[corev1.ResourceCPU])
+	assert.Equal(t, []corev1.EnvVar{{Name: "DEBUG", Value: "1"}}, containers[0].Env)
+}
+
+func TestApplyOverrideForbidden(t *testing.T) {
+	deployment := GenerateDeployment(testDeploymentConfig())
+	override := `{
+  "spec": {
+    "replicas": 10,
+    "template": {"spec": {
+      "containers": [{"name": "spam", "image": "evil:latest", "resources": {"limits": {"cpu": "2"}}}],
+      "hostNetwork": true
+    }}
+  }
+}`
+	err := ApplyOverride(&deployment, []byte(override), OverrideOptions{AllowedPaths: testOverridePaths})
+	assert.Equal(t, []string{
+		"spec.replicas",
+		"spec.template.spec.containers[*].image",
+		"spec.template.spec.hostNetwork",
+	}, fieldErrorPaths(t, err))
+	assert.Equal(t, "registry.example.com/spam:1.2.3", deployment.Spec.Template.Spec.Containers[0].Image)
+
+	// adding a container only requires the container itself to be allowed
+	err = ApplyOverride(&deployment, []byte(`{"spec":{"template":{"spec":{"containers":[{"name":"sidecar"}]}}}}`),
+		OverrideOptions{AllowedPaths: testOverridePaths})
+	assert.Equal(t, []string{"spec.template.spec.containers[*]"}, fieldErrorPaths(t, err))
+
+	err = ApplyOverride(&deployment, []byte(`{"metadata":{"annotations":{"a":"b"}}}`), OverrideOptions{})
+	assert.Equal(t, []string{"metadata.annotations.a"}, fieldErrorPaths(t, err))
+}
+
+func TestApplyOverrideNewListElements(t *testing.T) {
+	deployment := GenerateDeployment(testDeploymentConfig())
+	options := OverrideOptions{AllowedPaths: []string{"spec.template.spec.containers[*].image"}}
+
+	// the permission for a field of existing containers does not allow
+	// adding containers, whatever fields they carry
+	err := ApplyOverride(&deployment, []byte(`
+spec:
+  template:
+    spec:
+      containers:
+        - name: miner
+          image: evil/miner:1
+`), options)
+	assert.Equal(t, []string{"spec.template.spec.containers[*]"}, fieldErrorPaths(t, err))
+	assert.Len(t, deployment.Spec.Template.Spec.Containers, 1)
+
+	err = ApplyOverride(&deployment, []byte(`{"spec":{"template":{"spec":{"containers":[{"name":"spam","$patch":"delete"}]}}}}`), options)
+	assert.Equal(t, []string{"spec.template.spec.containers[*]"}, fieldErrorPaths(t, err))
+
+	err = ApplyOverride(&deployment, []byte(`{"spec":{"template":{"spec":{"containers":[{"name":"spam","image":"spam:2"}]}}}}`), options)
+	assert.NoError(t, err)
+	assert.Equal(t, "spam:2", deployment.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestApplyOverrideMergePatch(t *testing.T) {
+	deployment := GenerateDeployment(testDeploymentConfig())
+	override := `{"spec":{"template":{"spec":{"tolerations":[{"key":"gpu","operator":"Exists"}],"nodeSelector":null}}}}`
+	deployment.Spec.Template.Spec.NodeSelector = map[string]string{"disk": "hdd"}
+
+	// lists are replaced as a whole by merge patches, so the list must be allowed
+	err := ApplyOverride(&deployment, []byte(override), OverrideOptions{
+		Type: MergePatchOverride, AllowedPaths: []string{"spec.template.spec.containers[*].resources"},
+	})
+	assert.Equal(t, []string{"spec.template.spec.nodeSelector", "spec.template.spec.tolerations"}, fieldErrorPaths(t, err))
+
+	err = ApplyOverride(&deployment, []byte(override), OverrideOptions{Type: MergePatchOverride, AllowedPaths: testOverridePaths})
+	assert.NoError(t, err)
+	assert.Nil(t, deployment.Spec.Template.Spec.NodeSelector)
+	assert.Equal(t, "gpu", deployment.Spec.Template.Spec.Tolerations[0].Key)
+}
+
+func TestApplyOverrideUnstructured(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Spam",
+		"metadata":   map[string]interface{}{"name": "spam"},
+		"spec":       map[string]interface{}{"size": int64(1), "flavour": "eggs"},
+	}}
+	err := ApplyOverride(obj, []byte("spec:\n  size: 3\n"), OverrideOptions{AllowedPaths: []string{"spec.size"}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"size": float64(3), "flavour": "eggs"}, obj.Object["spec"])
+
+	err = ApplyOverride(obj, []byte("spec:\n  size: 3\n"),
+		OverrideOptions{Type: StrategicMergeOverride, AllowedPaths: []string{"spec.size"}})
+	assert.Error(t, err)
+}
+
+func TestApplyOverrideInvalid(t *testing.T) {
+	deployment := GenerateDeployment(testDeploymentConfig())
+	options := OverrideOptions{AllowedPaths: []string{"spec.template.spec"}}
+
+	err := ApplyOverride(&deployment, []byte(`{"spec":{"template":{"spec":{"nodeSelectr":{"a":"b"}}}}}`), options)
+	assert.ErrorContains(t, err, "nodeSelectr")
+	assert.Nil(t, deployment.Spec.Template.Spec.NodeSelector)
+
+	assert.Error(t, ApplyOverride(&deployment, []byte(`[1, 2]`), options))
+	assert.NoError(t, ApplyOverride(&deployment, []byte(``), options))
+}
@@ -0,0 +1,82 @@
+package negotools
+
+// Secrets with generated passwords that do not change on every reconcile
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// GeneratedKeySpec describes how the value of a generated key is created:
+// with Policy if it is set, with GeneratePassword otherwise.
+type GeneratedKeySpec struct {
+	Length                uint
+	ExcludeAmbiguousChars bool
+	Policy                *PasswordPolicy
+}
+
+func (spec GeneratedKeySpec) generate() (string, error) {
+	if spec.Policy != nil {
+		return spec.Policy.Generate()
+	}
+	return GeneratePassword(spec.Length, spec.ExcludeAmbiguousChars)
+}
+
+// PasswordSecretSpec describes a Secret with fixed values in Data and
+// generated values for the keys of GeneratedKeys, e.g. the user name and
+// the password of database credentials.
+type PasswordSecretSpec struct {
+	Name          string
+	Namespace     string
+	Data          map[string]string
+	GeneratedKeys map[string]GeneratedKeySpec
+}
+
+// GeneratePasswordSecret creates the Secret of spec. Values of generated
+// keys are taken over from the live Secret if it has them (existing may be
+// nil), only missing keys get new values. Keys of the live Secret not in
+// spec are dropped. The second return value lists the keys that were newly
+// generated, sorted.
+func GeneratePasswordSecret(spec PasswordSecretSpec, existing *corev1.Secret) (corev1.Secret, []string, error) {
+	var data map[string]string = map[string]string{}
+	var created []string = []string{}
+	for _, key := range sets.List(sets.KeySet(spec.GeneratedKeys)) {
+		if _, ok := spec.Data[key]; ok {
+			return corev1.Secret{}, nil, fmt.Errorf("Secret %s/%s: key %q is fixed and generated at the same time", spec.Namespace, spec.Name, key)
+		}
+		if value, ok := existingSecretValue(existing, key); ok {
+			data[key] = value
+			continue
+		}
+		var keySpec GeneratedKeySpec = spec.GeneratedKeys[key]
+		if keySpec.Policy == nil && keySpec.Length == 0 {
+			return corev1.Secret{}, nil, fmt.Errorf("Secret %s/%s: key %q needs a length", spec.Namespace, spec.Name, key)
+		}
+		password, err := keySpec.generate()
+		if err != nil {
+			return corev1.Secret{}, nil, fmt.Errorf("Secret %s/%s: key %q: %w", spec.Namespace, spec.Name, key, err)
+		}
+		data[key] = password
+		created = append(created, key)
+	}
+	for key, value := range spec.Data {
+		data[key] = value
+	}
+	return GenerateSecret(spec.Name, spec.Namespace, data), created, nil
+}
+
+// non-empty value of a key in data or stringData of a live Secret
+func existingSecretValue(secret *corev1.Secret, key string) (string, bool) {
+	if secret == nil {
+		return "", false
+	}
+	if value, ok := secret.StringData[key]; ok && value != "" {
+		return value, true
+	}
+	if value, ok := secret.Data[key]; ok && len(value) > 0 {
+		return string(value), true
+	}
+	return "", false
+}
@@ -0,0 +1,76 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func testPasswordSecretSpec() PasswordSecretSpec {
+	return PasswordSecretSpec{
+		Name:      "spam-db",
+		Namespace: "eggs",
+		Data:      map[string]string{"username": "spam"},
+		GeneratedKeys: map[string]GeneratedKeySpec{
+			"password":       {Length: 24, ExcludeAmbiguousChars: true},
+			"admin-password": {Length: 32},
+		},
+	}
+}
+
+func TestGeneratePasswordSecret(t *testing.T) {
+	secret, created, err := GeneratePasswordSecret(testPasswordSecretSpec(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin-password", "password"}, created)
+	assert.Equal(t, "spam", secret.StringData["username"])
+	assert.Len(t, secret.StringData["password"], 24)
+	assert.Len(t, secret.StringData["admin-password"], 32)
+
+	// the live Secret as returned by the API server, with one key missing
+	// and one key that is no longer in the spec
+	live := corev1.Secret{Data: map[string][]byte{
+		"password": []byte(secret.StringData["password"]),
+		"username": []byte("old"),
+		"obsolete": []byte("ham"),
+	}}
+	again, created, err := GeneratePasswordSecret(testPasswordSecretSpec(), &live)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin-password"}, created)
+	assert.Equal(t, secret.StringData["password"], again.StringData["password"])
+	assert.NotEqual(t, secret.StringData["admin-password"], again.StringData["admin-password"])
+	assert.Equal(t, "spam", again.StringData["username"], "fixed values come from the spec")
+	assert.NotContains(t, again.StringData, "obsolete")
+
+	live.Data["admin-password"] = []byte(again.StringData["admin-password"])
+	_, created, err = GeneratePasswordSecret(testPasswordSecretSpec(), &live)
+	assert.NoError(t, err)
+	assert.Empty(t, created)
+}
+
+func TestGeneratePasswordSecretPolicy(t *testing.T) {
+	policy := URLSafePasswordPolicy(20)
+	spec := testPasswordSecretSpec()
+	spec.GeneratedKeys["password"] = GeneratedKeySpec{Policy: &policy}
+	secret, _, err := GeneratePasswordSecret(spec, nil)
+	assert.NoError(t, err)
+	assert.Len(t, secret.StringData["password"], 20)
+	assert.Equal(t, uint(20), countChars(secret.StringData["password"],
+		PasswordLowercaseChars+PasswordUppercaseChars+PasswordDigitChars+URLSafePasswordSymbols))
+
+	policy.MinDigits = 30
+	_, _, err = GeneratePasswordSecret(spec, nil)
+	assert.ErrorContains(t, err, `Secret eggs/spam-db: key "password": Length`)
+}
+
+func TestGeneratePasswordSecretInvalidSpec(t *testing.T) {
+	spec := testPasswordSecretSpec()
+	spec.GeneratedKeys["username"] = GeneratedKeySpec{Length: 8}
+	_, _, err := GeneratePasswordSecret(spec, nil)
+	assert.EqualError(t, err, `Secret eggs/spam-db: key "username" is fixed and generated at the same time`)
+
+	spec = testPasswordSecretSpec()
+	spec.GeneratedKeys["password"] = GeneratedKeySpec{}
+	_, _, err = GeneratePasswordSecret(spec, nil)
+	assert.EqualError(t, err, `Secret eggs/spam-db: key "password" needs a length`)
+}
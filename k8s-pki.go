@@ -0,0 +1,265 @@
+package negotools
+
+// small PKI for TLS between components inside the cluster: a self-signed
+// CA that issues server and client certificates into kubernetes.io/tls
+// Secrets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ClusterDomain is the default DNS domain of Kubernetes clusters.
+const ClusterDomain string = "cluster.local"
+
+// CACertKey is the key of the CA certificate in issued TLS Secrets, as
+// used by cert-manager.
+const CACertKey string = "ca.crt"
+
+// DefaultCAValidity and DefaultCertificateValidity are used if no validity
+// is given.
+const (
+	DefaultCAValidity          time.Duration = 10 * 365 * 24 * time.Hour
+	DefaultCertificateValidity time.Duration = 365 * 24 * time.Hour
+)
+
+// certificates are valid a little earlier than issued, for nodes with a
+// clock behind the one of the issuer
+const clockSkew time.Duration = 5 * time.Minute
+
+// CertificateAuthority issues certificates. CertPEM and KeyPEM are the PEM
+// encoded certificate and private key, e.g. to be stored with Secret.
+type CertificateAuthority struct {
+	Certificate *x509.Certificate
+	CertPEM     []byte
+	KeyPEM      []byte
+	key         *ecdsa.PrivateKey
+}
+
+// CertificateRequest describes a certificate to issue. Usages defaults to
+// server authentication, Validity to DefaultCertificateValidity.
+type CertificateRequest struct {
+	CommonName string
+	DNSNames   []string
+	Usages     []x509.ExtKeyUsage
+	Validity   time.Duration
+}
+
+// ServiceDNSNames returns the names a Service is reachable with inside the
+// cluster: svc, svc.ns, svc.ns.svc and svc.ns.svc.cluster.local.
+func ServiceDNSNames(serviceName, namespace string) []string {
+	return []string{
+		serviceName,
+		serviceName + "." + namespace,
+		serviceName + "." + namespace + ".svc",
+		serviceName + "." + namespace + ".svc." + ClusterDomain,
+	}
+}
+
+// ServerCertificateRequest requests a server certificate for a Service.
+func ServerCertificateRequest(serviceName, namespace string) CertificateRequest {
+	return CertificateRequest{
+		CommonName: serviceName + "." + namespace + ".svc",
+		DNSNames:   ServiceDNSNames(serviceName, namespace),
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+}
+
+// ClientCertificateRequest requests a client certificate, the common name
+// identifies the client.
+func ClientCertificateRequest(commonName string) CertificateRequest {
+	return CertificateRequest{
+		CommonName: commonName,
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+}
+
+// NewCertificateAuthority creates a self-signed CA with an ECDSA P-256 key.
+// A validity of 0 means DefaultCAValidity.
+func NewCertificateAuthority(commonName string, validity time.Duration) (*CertificateAuthority, error) {
+	if validity == 0 {
+		validity = DefaultCAValidity
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	var now time.Time = time.Now()
+	var template *x509.Certificate = &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &CertificateAuthority{
+		Certificate: certificate,
+		CertPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:      keyPEM,
+		key:         key,
+	}, nil
+}
+
+// LoadCertificateAuthority reads a CA from a TLS Secret as created by
+// Secret, so the same CA can be used across runs.
+func LoadCertificateAuthority(secret corev1.Secret) (*CertificateAuthority, error) {
+	var certPEM, keyPEM []byte = secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Secret %s/%s: the CA key must be an ECDSA key", secret.Namespace, secret.Name)
+	}
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if !certificate.IsCA {
+		return nil, fmt.Errorf("Secret %s/%s: %q is no CA certificate", secret.Namespace, secret.Name, certificate.Subject.CommonName)
+	}
+	return &CertificateAuthority{Certificate: certificate, CertPEM: certPEM, KeyPEM: keyPEM, key: key}, nil
+}
+
+// Secret packages the CA certificate and key as kubernetes.io/tls Secret.
+func (ca *CertificateAuthority) Secret(name, namespaceName string) corev1.Secret {
+	return GenerateTLSSecret(name, namespaceName, ca.CertPEM, ca.KeyPEM)
+}
+
+// Issue creates a new key and a certificate signed by the CA and returns
+// both PEM encoded.
+func (ca *CertificateAuthority) Issue(request CertificateRequest) ([]byte, []byte, error) {
+	if request.CommonName == "" && len(request.DNSNames) == 0 {
+		return nil, nil, errors.New("a certificate needs a common name or DNS names")
+	}
+	var validity time.Duration = request.Validity
+	if validity == 0 {
+		validity = DefaultCertificateValidity
+	}
+	var usages []x509.ExtKeyUsage = request.Usages
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	var now time.Time = time.Now()
+	var notAfter time.Time = now.Add(validity)
+	if notAfter.After(ca.Certificate.NotAfter) {
+		notAfter = ca.Certificate.NotAfter
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	var template *x509.Certificate = &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: request.CommonName},
+		DNSNames:              request.DNSNames,
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           usages,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+// IssueSecret issues a certificate and packages it as kubernetes.io/tls
+// Secret. The CA certificate is added as ca.crt for the peers to verify.
+func (ca *CertificateAuthority) IssueSecret(name, namespaceName string, request CertificateRequest) (corev1.Secret, error) {
+	certPEM, keyPEM, err := ca.Issue(request)
+	if err != nil {
+		return corev1.Secret{}, err
+	}
+	var secret corev1.Secret = GenerateTLSSecret(name, namespaceName, certPEM, keyPEM)
+	secret.Data[CACertKey] = ca.CertPEM
+	return secret, nil
+}
+
+// NeedsRenewal decides whether the certificate in an existing TLS Secret
+// has to be issued again for request: if it is missing or invalid, not
+// signed by this CA, in the last third of its lifetime at now, or its DNS
+// names or common name differ from the request. The second return value
+// tells why.
+func (ca *CertificateAuthority) NeedsRenewal(secret corev1.Secret, request CertificateRequest, now time.Time) (bool, string) {
+	pair, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return true, "invalid key pair: " + err.Error()
+	}
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return true, "invalid certificate: " + err.Error()
+	}
+	if err := certificate.CheckSignatureFrom(ca.Certificate); err != nil {
+		return true, "not signed by the CA " + ca.Certificate.Subject.CommonName
+	}
+	var lifetime time.Duration = certificate.NotAfter.Sub(certificate.NotBefore)
+	var renewAt time.Time = certificate.NotAfter.Add(-lifetime / 3)
+	if !now.Before(renewAt) {
+		return true, fmt.Sprintf("expires at %s", certificate.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if certificate.Subject.CommonName != request.CommonName {
+		return true, fmt.Sprintf("common name changed from %q to %q", certificate.Subject.CommonName, request.CommonName)
+	}
+	var current, requested sets.Set[string] = sets.New(certificate.DNSNames...), sets.New(request.DNSNames...)
+	if !current.Equal(requested) {
+		return true, fmt.Sprintf("DNS names changed, added %v, removed %v",
+			sets.List(requested.Difference(current)), sets.List(current.Difference(requested)))
+	}
+	return false, ""
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encodePrivateKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
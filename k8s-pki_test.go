@@ -0,0 +1,101 @@
+package negotools
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func parseTestCertificate(t *testing.T, certPEM []byte) *x509.Certificate {
+	block, _ := pem.Decode(certPEM)
+	assert.NotNil(t, block)
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+	return certificate
+}
+
+func TestServiceDNSNames(t *testing.T) {
+	assert.Equal(t, []string{"spam", "spam.eggs", "spam.eggs.svc", "spam.eggs.svc.cluster.local"},
+		ServiceDNSNames("spam", "eggs"))
+}
+
+func TestCertificateAuthorityIssueSecret(t *testing.T) {
+	ca, err := NewCertificateAuthority("spam-ca", 0)
+	assert.NoError(t, err)
+	assert.True(t, ca.Certificate.IsCA)
+
+	// the CA survives the round trip through its Secret
+	caSecret := ca.Secret("spam-ca", "eggs")
+	assert.NoError(t, ValidateSecret(caSecret))
+	loaded, err := LoadCertificateAuthority(caSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, ca.Certificate.Raw, loaded.Certificate.Raw)
+
+	secret, err := loaded.IssueSecret("spam-tls", "eggs", ServerCertificateRequest("spam", "eggs"))
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	assert.Equal(t, ca.CertPEM, secret.Data["ca.crt"])
+	assert.NoError(t, ValidateSecret(secret))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate)
+	certificate := parseTestCertificate(t, secret.Data["tls.crt"])
+	_, err = certificate.Verify(x509.VerifyOptions{DNSName: "spam.eggs.svc.cluster.local", Roots: roots})
+	assert.NoError(t, err)
+	_, err = certificate.Verify(x509.VerifyOptions{DNSName: "ham.eggs.svc", Roots: roots})
+	assert.Error(t, err)
+	_, err = certificate.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	assert.Error(t, err, "server certificates must not be usable by clients")
+
+	certPEM, _, err := ca.Issue(ClientCertificateRequest("ham"))
+	assert.NoError(t, err)
+	certificate = parseTestCertificate(t, certPEM)
+	assert.Equal(t, "ham", certificate.Subject.CommonName)
+	_, err = certificate.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	assert.NoError(t, err)
+
+	_, _, err = ca.Issue(CertificateRequest{})
+	assert.Error(t, err)
+	_, err = LoadCertificateAuthority(secret)
+	assert.ErrorContains(t, err, "is no CA certificate")
+}
+
+func TestCertificateAuthorityNeedsRenewal(t *testing.T) {
+	ca, err := NewCertificateAuthority("spam-ca", 0)
+	assert.NoError(t, err)
+	request := ServerCertificateRequest("spam", "eggs")
+	request.Validity = 30 * 24 * time.Hour
+	secret, err := ca.IssueSecret("spam-tls", "eggs", request)
+	assert.NoError(t, err)
+	var now time.Time = time.Now()
+
+	renew, reason := ca.NeedsRenewal(secret, request, now)
+	assert.False(t, renew, reason)
+
+	renew, reason = ca.NeedsRenewal(secret, request, now.Add(25*24*time.Hour))
+	assert.True(t, renew)
+	assert.Contains(t, reason, "expires at")
+
+	changed := ServerCertificateRequest("spam", "eggs")
+	changed.DNSNames = append(changed.DNSNames, "spam.example.com")
+	renew, reason = ca.NeedsRenewal(secret, changed, now)
+	assert.True(t, renew)
+	assert.Equal(t, "DNS names changed, added [spam.example.com], removed []", reason)
+
+	renew, reason = ca.NeedsRenewal(secret, ServerCertificateRequest("spam", "ham"), now)
+	assert.True(t, renew)
+	assert.Contains(t, reason, "common name changed")
+
+	other, err := NewCertificateAuthority("other-ca", 0)
+	assert.NoError(t, err)
+	renew, reason = other.NeedsRenewal(secret, request, now)
+	assert.True(t, renew)
+	assert.Equal(t, "not signed by the CA other-ca", reason)
+
+	renew, _ = ca.NeedsRenewal(corev1.Secret{}, request, now)
+	assert.True(t, renew)
+}
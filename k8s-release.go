@@ -0,0 +1,189 @@
+package negotools
+
+// blue/green and canary releases with a stable and a candidate Deployment
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// selector label separating the pods of the two Deployments
+	ReleaseTrackLabel string = "negotools.deepshore.de/track"
+	AppVersionLabel   string = "app.kubernetes.io/version"
+
+	ReleaseTrackStable    string = "stable"
+	ReleaseTrackCandidate string = "candidate"
+
+	NginxCanaryAnnotation       string = "nginx.ingress.kubernetes.io/canary"
+	NginxCanaryWeightAnnotation string = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+type ReleaseStrategy string
+
+const (
+	// all traffic goes to the track selected by ActiveTrack
+	BlueGreenRelease ReleaseStrategy = "blue-green"
+	// CandidateTrafficPercent of the Ingress traffic goes to the candidate
+	CanaryRelease ReleaseStrategy = "canary"
+)
+
+// ReleaseConfig describes a release running a stable and a candidate version
+// of the same Deployment side by side.
+type ReleaseConfig struct {
+	// template of both Deployments; Name, MatchLabels and PodLabels are
+	// extended per track, Image is replaced by StableImage/CandidateImage
+	Deployment     DeploymentConfig
+	StableImage    string
+	CandidateImage string
+	// replicas of the candidate, defaults to Deployment.Replicas
+	CandidateReplicas *int32
+	Strategy          ReleaseStrategy
+	// track the Service selects with BlueGreenRelease, defaults to stable
+	ActiveTrack string
+	// share of the traffic (0-100) for the candidate with CanaryRelease
+	CandidateTrafficPercent int32
+	// port of the Services, defaults to 80
+	ServicePort int32
+	// optional, not generated if nil; canary traffic shifting needs it
+	Ingress *AppIngressConfig
+}
+
+// ReleaseBundle holds the objects of a release. Candidate objects are nil
+// if there is no CandidateImage, e.g. after the candidate was promoted.
+type ReleaseBundle struct {
+	StableDeployment    *appsv1.Deployment
+	CandidateDeployment *appsv1.Deployment
+	// named like the Deployment config, selects the active (blue/green) or
+	// the stable (canary) track
+	Service *corev1.Service
+	// "<name>-candidate", always selects the candidate, e.g. for tests
+	// before switching
+	CandidateService *corev1.Service
+	Ingress          *networking.Ingress
+	// NGINX canary Ingress routing the candidate's share of the traffic
+	CanaryIngress *networking.Ingress
+}
+
+func (config ReleaseConfig) activeTrack() string {
+	if config.Strategy == CanaryRelease || config.ActiveTrack == "" {
+		return ReleaseTrackStable
+	}
+	return config.ActiveTrack
+}
+
+func (config ReleaseConfig) portName() string {
+	if config.Deployment.PortName == "" {
+		return defaultPortName
+	}
+	return config.Deployment.PortName
+}
+
+// selector of the pods of a track
+func (config ReleaseConfig) trackSelector(track string) map[string]string {
+	var selector map[string]string = map[string]string{}
+	for key, value := range config.Deployment.MatchLabels {
+		selector[key] = value
+	}
+	selector[ReleaseTrackLabel] = track
+	return selector
+}
+
+func (config ReleaseConfig) trackDeployment(track, image string) appsv1.Deployment {
+	var deploymentConfig DeploymentConfig = config.Deployment
+	deploymentConfig.Name = DerivedName(config.Deployment.Name, track)
+	deploymentConfig.Image = image
+	deploymentConfig.PortName = config.portName()
+	deploymentConfig.MatchLabels = config.trackSelector(track)
+	deploymentConfig.PodLabels = map[string]string{}
+	for key, value := range config.Deployment.PodLabels {
+		deploymentConfig.PodLabels[key] = value
+	}
+	for key, value := range deploymentConfig.MatchLabels {
+		deploymentConfig.PodLabels[key] = value
+	}
+	// only on the pods, so a new version needs no new selector
+	if ref, err := ParseImageReference(image); err == nil && ref.Tag != "" && len(validation.IsValidLabelValue(ref.Tag)) == 0 {
+		deploymentConfig.PodLabels[AppVersionLabel] = ref.Tag
+	}
+	if track == ReleaseTrackCandidate && config.CandidateReplicas != nil {
+		deploymentConfig.Replicas = *config.CandidateReplicas
+	}
+	return GenerateDeployment(deploymentConfig)
+}
+
+// GenerateRelease generates the Deployments of both tracks, the Services and
+// the optional Ingresses of a release. Switching a blue/green release means
+// regenerating with another ActiveTrack, shifting canary traffic means
+// regenerating with another CandidateTrafficPercent.
+func GenerateRelease(config ReleaseConfig) ReleaseBundle {
+	var bundle ReleaseBundle = ReleaseBundle{}
+	var name string = config.Deployment.Name
+	var namespace string = config.Deployment.Namespace
+	var portName string = config.portName()
+	var servicePort int32 = config.ServicePort
+	if servicePort == 0 {
+		servicePort = defaultPort
+	}
+
+	stable := config.trackDeployment(ReleaseTrackStable, config.StableImage)
+	bundle.StableDeployment = &stable
+	service := GenerateService(name, namespace, config.trackSelector(config.activeTrack()),
+		portName, servicePort, intstr.FromString(portName))
+	bundle.Service = &service
+	if config.CandidateImage != "" {
+		candidate := config.trackDeployment(ReleaseTrackCandidate, config.CandidateImage)
+		bundle.CandidateDeployment = &candidate
+		candidateService := GenerateService(DerivedName(name, ReleaseTrackCandidate), namespace,
+			config.trackSelector(ReleaseTrackCandidate), portName, servicePort, intstr.FromString(portName))
+		bundle.CandidateService = &candidateService
+	}
+
+	if config.Ingress != nil {
+		ingress := GenerateIngress(name, namespace, config.Ingress.DnsUri, config.Ingress.IngressBaseUrl,
+			portName, config.Ingress.Path, config.Ingress.IngressClassName, service.Name, config.Ingress.PathType)
+		bundle.Ingress = &ingress
+		if config.Strategy == CanaryRelease && bundle.CandidateService != nil {
+			canary := GenerateIngress(DerivedName(name, "canary"), namespace, config.Ingress.DnsUri, config.Ingress.IngressBaseUrl,
+				portName, config.Ingress.Path, config.Ingress.IngressClassName, bundle.CandidateService.Name,
+				config.Ingress.PathType)
+			canary.Annotations = map[string]string{
+				NginxCanaryAnnotation:       "true",
+				NginxCanaryWeightAnnotation: strconv.Itoa(int(config.CandidateTrafficPercent)),
+			}
+			bundle.CanaryIngress = &canary
+		}
+	}
+	return bundle
+}
+
+// Objects returns the generated objects: Deployments, Services and
+// Ingresses, stable before candidate, leaving out nil ones.
+func (bundle ReleaseBundle) Objects() []runtime.Object {
+	var objects []runtime.Object = []runtime.Object{}
+	if bundle.StableDeployment != nil {
+		objects = append(objects, bundle.StableDeployment)
+	}
+	if bundle.CandidateDeployment != nil {
+		objects = append(objects, bundle.CandidateDeployment)
+	}
+	if bundle.Service != nil {
+		objects = append(objects, bundle.Service)
+	}
+	if bundle.CandidateService != nil {
+		objects = append(objects, bundle.CandidateService)
+	}
+	if bundle.Ingress != nil {
+		objects = append(objects, bundle.Ingress)
+	}
+	if bundle.CanaryIngress != nil {
+		objects = append(objects, bundle.CanaryIngress)
+	}
+	return objects
+}
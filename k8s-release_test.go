@@ -0,0 +1,105 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networking "k8s.io/api/networking/v1"
+)
+
+func testReleaseConfig() ReleaseConfig {
+	return ReleaseConfig{
+		Deployment:     testDeploymentConfig(),
+		StableImage:    "registry.example.com/spam:1.2.3",
+		CandidateImage: "registry.example.com/spam:1.3.0",
+		Strategy:       BlueGreenRelease,
+		Ingress: &AppIngressConfig{
+			DnsUri:         "spam",
+			IngressBaseUrl: "example.com",
+			Path:           "/",
+			PathType:       networking.PathTypePrefix,
+		},
+	}
+}
+
+func TestGenerateReleaseBlueGreen(t *testing.T) {
+	config := testReleaseConfig()
+	replicas := int32(1)
+	config.CandidateReplicas = &replicas
+	bundle := GenerateRelease(config)
+	assert.Len(t, bundle.Objects(), 5)
+	assert.Nil(t, bundle.CanaryIngress)
+
+	stable, candidate := bundle.StableDeployment, bundle.CandidateDeployment
+	assert.Equal(t, "spam-stable", stable.Name)
+	assert.Equal(t, "spam-candidate", candidate.Name)
+	assert.Equal(t, "registry.example.com/spam:1.3.0", candidate.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, map[string]string{"app": "spam", ReleaseTrackLabel: ReleaseTrackCandidate}, candidate.Spec.Selector.MatchLabels)
+	assert.Equal(t, "1.2.3", stable.Spec.Template.Labels[AppVersionLabel])
+	assert.Equal(t, "1.3.0", candidate.Spec.Template.Labels[AppVersionLabel])
+	assert.Equal(t, int32(2), *stable.Spec.Replicas)
+	assert.Equal(t, int32(1), *candidate.Spec.Replicas)
+
+	assert.Equal(t, ReleaseTrackStable, bundle.Service.Spec.Selector[ReleaseTrackLabel])
+	assert.Equal(t, ReleaseTrackCandidate, bundle.CandidateService.Spec.Selector[ReleaseTrackLabel])
+	assert.Equal(t, "spam", bundle.Ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name)
+
+	config.ActiveTrack = ReleaseTrackCandidate
+	bundle = GenerateRelease(config)
+	assert.Equal(t, ReleaseTrackCandidate, bundle.Service.Spec.Selector[ReleaseTrackLabel])
+	assert.Equal(t, "spam", bundle.Service.Name)
+}
+
+func TestGenerateReleaseCanary(t *testing.T) {
+	config := testReleaseConfig()
+	config.Strategy = CanaryRelease
+	config.ActiveTrack = ReleaseTrackCandidate
+	config.CandidateTrafficPercent = 20
+	bundle := GenerateRelease(config)
+	assert.Len(t, bundle.Objects(), 6)
+
+	assert.Equal(t, ReleaseTrackStable, bundle.Service.Spec.Selector[ReleaseTrackLabel])
+	assert.Equal(t, "spam-canary", bundle.CanaryIngress.Name)
+	assert.Equal(t, map[string]string{NginxCanaryAnnotation: "true", NginxCanaryWeightAnnotation: "20"},
+		bundle.CanaryIngress.Annotations)
+	assert.Equal(t, bundle.Ingress.Spec.Rules[0].Host, bundle.CanaryIngress.Spec.Rules[0].Host)
+	assert.Equal(t, "spam-candidate", bundle.CanaryIngress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name)
+	assert.Empty(t, bundle.Ingress.Annotations)
+
+	// promoted: the former candidate is the new stable version
+	config.StableImage, config.CandidateImage = config.CandidateImage, ""
+	bundle = GenerateRelease(config)
+	assert.Len(t, bundle.Objects(), 3)
+	assert.Nil(t, bundle.CanaryIngress)
+	assert.Equal(t, "1.3.0", bundle.StableDeployment.Spec.Template.Labels[AppVersionLabel])
+}
+
+func TestReleaseConfigValidate(t *testing.T) {
+	assert.NoError(t, testReleaseConfig().Validate())
+
+	config := testReleaseConfig()
+	config.Strategy = "rolling"
+	config.ActiveTrack = "blue"
+	config.CandidateTrafficPercent = 120
+	config.CandidateImage = "registry.example.com/Spam"
+	config.Ingress.Path = "spam"
+	assert.Equal(t, []string{
+		"Strategy",
+		"ActiveTrack",
+		"CandidateTrafficPercent",
+		"CandidateImage",
+		"Ingress.path",
+	}, fieldErrorPaths(t, config.Validate()))
+
+	config = testReleaseConfig()
+	config.Strategy = CanaryRelease
+	config.Ingress = nil
+	config.StableImage = ""
+	assert.Equal(t, []string{"Ingress", "Deployment.Image"}, fieldErrorPaths(t, config.Validate()))
+
+	// DerivedName would sanitize the name, the stable Service does not
+	config = testReleaseConfig()
+	config.Deployment.Name = "spam.v2"
+	config.Ingress = nil
+	assert.Equal(t, []string{"Deployment.Name"}, fieldErrorPaths(t, config.Validate()))
+}
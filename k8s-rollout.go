@@ -0,0 +1,322 @@
+package negotools
+
+// waiting for rollouts of Deployments and StatefulSets, following the logic
+// of "kubectl rollout status"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+type RolloutReason string
+
+const (
+	RolloutReasonComplete                 RolloutReason = "Complete"
+	RolloutReasonProgressing              RolloutReason = "Progressing"
+	RolloutReasonProgressDeadlineExceeded RolloutReason = "ProgressDeadlineExceeded"
+	RolloutReasonUnsupportedStrategy      RolloutReason = "UnsupportedStrategy"
+	RolloutReasonNotFound                 RolloutReason = "NotFound"
+	RolloutReasonDeleted                  RolloutReason = "Deleted"
+	RolloutReasonTimeout                  RolloutReason = "Timeout"
+)
+
+// RolloutStatus is a snapshot of a rollout, explaining why it is (not) done.
+type RolloutStatus struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Done      bool
+	Reason    RolloutReason
+	Message   string
+	// generation of the spec and the generation observed by the controller
+	Generation         int64
+	ObservedGeneration int64
+	DesiredReplicas    int32
+	UpdatedReplicas    int32
+	ReadyReplicas      int32
+	AvailableReplicas  int32
+}
+
+// Failed reports whether the rollout ended without becoming complete.
+func (s RolloutStatus) Failed() bool {
+	return !s.Done && s.Reason != RolloutReasonProgressing
+}
+
+func (s RolloutStatus) String() string {
+	return fmt.Sprintf("%s %s/%s: %s: %s", s.Kind, s.Namespace, s.Name, s.Reason, s.Message)
+}
+
+// RolloutError is returned by the wait functions if a rollout failed or did
+// not finish in time.
+type RolloutError struct {
+	Status RolloutStatus
+}
+
+func (e *RolloutError) Error() string {
+	return "rollout failed: " + e.Status.String()
+}
+
+// DeploymentRolloutStatus evaluates the status of a Deployment like
+// "kubectl rollout status" does.
+func DeploymentRolloutStatus(deployment *appsv1.Deployment) RolloutStatus {
+	var status RolloutStatus = RolloutStatus{
+		Kind:               "Deployment",
+		Namespace:          deployment.Namespace,
+		Name:               deployment.Name,
+		Reason:             RolloutReasonProgressing,
+		Generation:         deployment.Generation,
+		ObservedGeneration: deployment.Status.ObservedGeneration,
+		DesiredReplicas:    1,
+		UpdatedReplicas:    deployment.Status.UpdatedReplicas,
+		ReadyReplicas:      deployment.Status.ReadyReplicas,
+		AvailableReplicas:  deployment.Status.AvailableReplicas,
+	}
+	if deployment.Spec.Replicas != nil {
+		status.DesiredReplicas = *deployment.Spec.Replicas
+	}
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		status.Message = "waiting for deployment spec update to be observed"
+		return status
+	}
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			status.Reason = RolloutReasonProgressDeadlineExceeded
+			status.Message = fmt.Sprintf("deployment exceeded its progress deadline: %s", condition.Message)
+			return status
+		}
+	}
+	if deployment.Status.UpdatedReplicas < status.DesiredReplicas {
+		status.Message = fmt.Sprintf("%d out of %d new replicas have been updated",
+			deployment.Status.UpdatedReplicas, status.DesiredReplicas)
+		return status
+	}
+	if deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
+		status.Message = fmt.Sprintf("%d old replicas are pending termination",
+			deployment.Status.Replicas-deployment.Status.UpdatedReplicas)
+		return status
+	}
+	if deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas {
+		status.Message = fmt.Sprintf("%d of %d updated replicas are available",
+			deployment.Status.AvailableReplicas, deployment.Status.UpdatedReplicas)
+		return status
+	}
+	status.Done = true
+	status.Reason = RolloutReasonComplete
+	status.Message = "successfully rolled out"
+	return status
+}
+
+// StatefulSetRolloutStatus evaluates the status of a StatefulSet like
+// "kubectl rollout status" does. Only the RollingUpdate strategy can be
+// tracked.
+func StatefulSetRolloutStatus(statefulSet *appsv1.StatefulSet) RolloutStatus {
+	var status RolloutStatus = RolloutStatus{
+		Kind:               "StatefulSet",
+		Namespace:          statefulSet.Namespace,
+		Name:               statefulSet.Name,
+		Reason:             RolloutReasonProgressing,
+		Generation:         statefulSet.Generation,
+		ObservedGeneration: statefulSet.Status.ObservedGeneration,
+		DesiredReplicas:    1,
+		UpdatedReplicas:    statefulSet.Status.UpdatedReplicas,
+		ReadyReplicas:      statefulSet.Status.ReadyReplicas,
+		AvailableReplicas:  statefulSet.Status.AvailableReplicas,
+	}
+	if statefulSet.Spec.Replicas != nil {
+		status.DesiredReplicas = *statefulSet.Spec.Replicas
+	}
+	if statefulSet.Spec.UpdateStrategy.Type != "" &&
+		statefulSet.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		status.Reason = RolloutReasonUnsupportedStrategy
+		status.Message = fmt.Sprintf("rollout status is only available for %s strategy type",
+			appsv1.RollingUpdateStatefulSetStrategyType)
+		return status
+	}
+	if statefulSet.Status.ObservedGeneration == 0 || statefulSet.Generation > statefulSet.Status.ObservedGeneration {
+		status.Message = "waiting for statefulset spec update to be observed"
+		return status
+	}
+	if statefulSet.Status.ReadyReplicas < status.DesiredReplicas {
+		status.Message = fmt.Sprintf("%d of %d pods are ready", statefulSet.Status.ReadyReplicas, status.DesiredReplicas)
+		return status
+	}
+	var rollingUpdate *appsv1.RollingUpdateStatefulSetStrategy = statefulSet.Spec.UpdateStrategy.RollingUpdate
+	if rollingUpdate != nil && rollingUpdate.Partition != nil && *rollingUpdate.Partition > 0 {
+		var expected int32 = status.DesiredReplicas - *rollingUpdate.Partition
+		if statefulSet.Status.UpdatedReplicas < expected {
+			status.Message = fmt.Sprintf("%d of %d pods have been updated for the partitioned roll out",
+				statefulSet.Status.UpdatedReplicas, expected)
+			return status
+		}
+		status.Done = true
+		status.Reason = RolloutReasonComplete
+		status.Message = fmt.Sprintf("partitioned roll out complete: %d new pods have been updated", statefulSet.Status.UpdatedReplicas)
+		return status
+	}
+	if statefulSet.Status.UpdateRevision != statefulSet.Status.CurrentRevision {
+		status.Message = fmt.Sprintf("%d pods at revision %s, waiting for the remaining pods",
+			statefulSet.Status.UpdatedReplicas, statefulSet.Status.UpdateRevision)
+		return status
+	}
+	status.Done = true
+	status.Reason = RolloutReasonComplete
+	status.Message = fmt.Sprintf("rolling update complete: %d pods at revision %s",
+		statefulSet.Status.CurrentReplicas, statefulSet.Status.CurrentRevision)
+	return status
+}
+
+// WaitForDeploymentRollout blocks until the rollout of the Deployment is
+// complete, has failed or the context is done. Use a context with timeout to
+// limit the waiting time.
+func WaitForDeploymentRollout(ctx context.Context, client kubernetes.Interface, namespace, name string) (RolloutStatus, error) {
+	var deployments = client.AppsV1().Deployments(namespace)
+	return waitForRollout(ctx, "Deployment", namespace, name,
+		func(ctx context.Context) (runtime.Object, error) {
+			return deployments.Get(ctx, name, metav1.GetOptions{})
+		},
+		deployments.Watch,
+		func(obj runtime.Object) (RolloutStatus, bool) {
+			deployment, ok := obj.(*appsv1.Deployment)
+			if !ok {
+				return RolloutStatus{}, false
+			}
+			return DeploymentRolloutStatus(deployment), true
+		},
+	)
+}
+
+// WaitForStatefulSetRollout blocks until the rollout of the StatefulSet is
+// complete, has failed or the context is done.
+func WaitForStatefulSetRollout(ctx context.Context, client kubernetes.Interface, namespace, name string) (RolloutStatus, error) {
+	var statefulSets = client.AppsV1().StatefulSets(namespace)
+	return waitForRollout(ctx, "StatefulSet", namespace, name,
+		func(ctx context.Context) (runtime.Object, error) {
+			return statefulSets.Get(ctx, name, metav1.GetOptions{})
+		},
+		statefulSets.Watch,
+		func(obj runtime.Object) (RolloutStatus, bool) {
+			statefulSet, ok := obj.(*appsv1.StatefulSet)
+			if !ok {
+				return RolloutStatus{}, false
+			}
+			return StatefulSetRolloutStatus(statefulSet), true
+		},
+	)
+}
+
+func waitForRollout(
+	ctx context.Context, kind, namespace, name string,
+	get func(ctx context.Context) (runtime.Object, error),
+	watchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error),
+	evaluate func(obj runtime.Object) (RolloutStatus, bool),
+) (RolloutStatus, error) {
+
+	var status RolloutStatus = RolloutStatus{
+		Kind: kind, Namespace: namespace, Name: name, Reason: RolloutReasonProgressing,
+	}
+	var timeout = func() (RolloutStatus, error) {
+		status.Reason = RolloutReasonTimeout
+		status.Message = fmt.Sprintf("timed out waiting for rollout: %v (last status: %s)", ctx.Err(), status.Message)
+		return status, &RolloutError{Status: status}
+	}
+
+	for {
+		obj, err := get(ctx)
+		if apierrors.IsNotFound(err) {
+			status.Reason = RolloutReasonNotFound
+			status.Message = err.Error()
+			return status, &RolloutError{Status: status}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return timeout()
+			}
+			return status, fmt.Errorf("failed to get %s %s/%s: %w", kind, namespace, name, err)
+		}
+		status, _ = evaluate(obj)
+		if done, err := rolloutFinished(status); done {
+			return status, err
+		}
+		LogDebug("Waiting for rollout", "Kind", kind, "Name", name, "Status", status.Message)
+
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return status, err
+		}
+		watcher, err := watchFunc(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: accessor.GetResourceVersion(),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return timeout()
+			}
+			return status, fmt.Errorf("failed to watch %s %s/%s: %w", kind, namespace, name, err)
+		}
+
+		var restart bool = false
+		for !restart {
+			select {
+			case <-ctx.Done():
+				watcher.Stop()
+				return timeout()
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					// watch expired, start over with a fresh object
+					restart = true
+					continue
+				}
+				switch event.Type {
+				case watch.Added, watch.Modified:
+					current, ok := evaluate(event.Object)
+					if !ok {
+						continue
+					}
+					status = current
+					if done, err := rolloutFinished(status); done {
+						watcher.Stop()
+						return status, err
+					}
+					LogDebug("Waiting for rollout", "Kind", kind, "Name", name, "Status", status.Message)
+				case watch.Deleted:
+					watcher.Stop()
+					status.Done = false
+					status.Reason = RolloutReasonDeleted
+					status.Message = fmt.Sprintf("%s was deleted during the rollout", kind)
+					return status, &RolloutError{Status: status}
+				case watch.Error:
+					LogWarning("Watch returned an error, restarting", apierrors.FromObject(event.Object), "Kind", kind, "Name", name)
+					watcher.Stop()
+					restart = true
+				}
+			}
+		}
+	}
+}
+
+func rolloutFinished(status RolloutStatus) (bool, error) {
+	if status.Done {
+		return true, nil
+	}
+	if status.Failed() {
+		return true, &RolloutError{Status: status}
+	}
+	return false, nil
+}
+
+// IsRolloutError reports whether err is (or wraps) a RolloutError with the
+// given reason.
+func IsRolloutError(err error, reason RolloutReason) bool {
+	var rolloutErr *RolloutError
+	return errors.As(err, &rolloutErr) && rolloutErr.Status.Reason == reason
+}
@@ -0,0 +1,143 @@
+package negotools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func rollingDeployment(generation, observed int64, updated, available, total int32) *appsv1.Deployment {
+	deployment := GenerateDeployment(testDeploymentConfig())
+	deployment.Generation = generation
+	deployment.ResourceVersion = "1"
+	deployment.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: observed,
+		Replicas:           total,
+		UpdatedReplicas:    updated,
+		AvailableReplicas:  available,
+		ReadyReplicas:      available,
+	}
+	return &deployment
+}
+
+func TestDeploymentRolloutStatus(t *testing.T) {
+	status := DeploymentRolloutStatus(rollingDeployment(2, 1, 2, 2, 2))
+	assert.False(t, status.Done)
+	assert.Equal(t, RolloutReasonProgressing, status.Reason)
+
+	status = DeploymentRolloutStatus(rollingDeployment(2, 2, 1, 1, 3))
+	assert.Equal(t, "1 out of 2 new replicas have been updated", status.Message)
+
+	status = DeploymentRolloutStatus(rollingDeployment(2, 2, 2, 2, 3))
+	assert.Equal(t, "1 old replicas are pending termination", status.Message)
+
+	status = DeploymentRolloutStatus(rollingDeployment(2, 2, 2, 1, 2))
+	assert.Equal(t, "1 of 2 updated replicas are available", status.Message)
+	assert.False(t, status.Failed())
+
+	status = DeploymentRolloutStatus(rollingDeployment(2, 2, 2, 2, 2))
+	assert.True(t, status.Done)
+	assert.Equal(t, RolloutReasonComplete, status.Reason)
+
+	deployment := rollingDeployment(2, 2, 1, 1, 2)
+	deployment.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:    appsv1.DeploymentProgressing,
+		Reason:  "ProgressDeadlineExceeded",
+		Message: `ReplicaSet "spam-5d8f" has timed out progressing.`,
+	}}
+	status = DeploymentRolloutStatus(deployment)
+	assert.True(t, status.Failed())
+	assert.Equal(t, RolloutReasonProgressDeadlineExceeded, status.Reason)
+}
+
+func TestStatefulSetRolloutStatus(t *testing.T) {
+	var replicas int32 = 3
+	statefulSet := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1.StatefulSetStatus{
+			ObservedGeneration: 1,
+			ReadyReplicas:      3,
+			UpdatedReplicas:    1,
+			CurrentRevision:    "spam-1",
+			UpdateRevision:     "spam-2",
+		},
+	}
+	statefulSet.Generation = 1
+	status := StatefulSetRolloutStatus(statefulSet)
+	assert.False(t, status.Done)
+	assert.Equal(t, "1 pods at revision spam-2, waiting for the remaining pods", status.Message)
+
+	statefulSet.Status.CurrentRevision = "spam-2"
+	statefulSet.Status.CurrentReplicas = 3
+	status = StatefulSetRolloutStatus(statefulSet)
+	assert.True(t, status.Done)
+
+	statefulSet.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+	status = StatefulSetRolloutStatus(statefulSet)
+	assert.True(t, status.Failed())
+	assert.Equal(t, RolloutReasonUnsupportedStrategy, status.Reason)
+}
+
+func TestWaitForDeploymentRollout(t *testing.T) {
+	client := fake.NewClientset(rollingDeployment(2, 1, 0, 0, 2))
+	watcher := watch.NewFake()
+	client.PrependWatchReactor("deployments", k8stesting.DefaultWatchReactor(watcher, nil))
+
+	go func() {
+		watcher.Modify(rollingDeployment(2, 2, 1, 0, 3))
+		watcher.Modify(rollingDeployment(2, 2, 2, 1, 2))
+		watcher.Modify(rollingDeployment(2, 2, 2, 2, 2))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := WaitForDeploymentRollout(ctx, client, "eggs", "spam")
+	assert.NoError(t, err)
+	assert.True(t, status.Done)
+	assert.Equal(t, int32(2), status.AvailableReplicas)
+}
+
+func TestWaitForDeploymentRolloutDeadlineExceeded(t *testing.T) {
+	client := fake.NewClientset(rollingDeployment(2, 2, 1, 1, 2))
+	watcher := watch.NewFake()
+	client.PrependWatchReactor("deployments", k8stesting.DefaultWatchReactor(watcher, nil))
+
+	go func() {
+		failed := rollingDeployment(2, 2, 1, 1, 2)
+		failed.Status.Conditions = []appsv1.DeploymentCondition{{
+			Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded",
+		}}
+		watcher.Modify(failed)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := WaitForDeploymentRollout(ctx, client, "eggs", "spam")
+	assert.Error(t, err)
+	assert.True(t, IsRolloutError(err, RolloutReasonProgressDeadlineExceeded))
+	assert.Equal(t, RolloutReasonProgressDeadlineExceeded, status.Reason)
+}
+
+func TestWaitForDeploymentRolloutTimeout(t *testing.T) {
+	client := fake.NewClientset(rollingDeployment(2, 2, 1, 1, 2))
+	watcher := watch.NewFake()
+	client.PrependWatchReactor("deployments", k8stesting.DefaultWatchReactor(watcher, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	status, err := WaitForDeploymentRollout(ctx, client, "eggs", "spam")
+	assert.True(t, IsRolloutError(err, RolloutReasonTimeout))
+	assert.Contains(t, status.Message, "1 out of 2 new replicas have been updated")
+}
+
+func TestWaitForDeploymentRolloutNotFound(t *testing.T) {
+	client := fake.NewClientset()
+	_, err := WaitForDeploymentRollout(context.Background(), client, "eggs", "spam")
+	assert.True(t, IsRolloutError(err, RolloutReasonNotFound))
+}
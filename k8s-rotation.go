@@ -0,0 +1,140 @@
+package negotools
+
+// rotation of generated Secret values: the previous value stays in the
+// Secret for a grace period, so clients can switch without downtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/clock"
+)
+
+// RotationAnnotation holds the creation timestamps of the current and
+// previous values of all rotated keys as JSON, e.g.
+// {"password":{"current":"2026-10-19T08:00:00Z","previous":"2026-07-21T08:00:00Z"}}.
+const RotationAnnotation string = "negotools.deepshore.de/rotation"
+
+// PreviousValueKeySuffix is appended to a key for its previous value, so
+// "password" is accompanied by "password_previous" during the grace
+// period.
+const PreviousValueKeySuffix string = "_previous"
+
+// RotationPolicy decides when generated values are rotated. A value is
+// replaced once it is older than MaxAge (0 never rotates), the previous
+// value is kept for GracePeriod after the rotation. Clock defaults to the
+// real clock.
+type RotationPolicy struct {
+	MaxAge      time.Duration
+	GracePeriod time.Duration
+	Clock       clock.PassiveClock
+}
+
+// RotationResult reports what RotatePasswordSecret changed. Keys are sorted.
+// NextChange is the time the Secret has to be rotated again at the latest,
+// zero if nothing is scheduled.
+type RotationResult struct {
+	Created         []string
+	Rotated         []string
+	DroppedPrevious []string
+	NextChange      time.Time
+}
+
+type rotationTimestamps struct {
+	Current  time.Time  `json:"current"`
+	Previous *time.Time `json:"previous,omitempty"`
+}
+
+// RotatePasswordSecret works like GeneratePasswordSecret and additionally
+// rotates the generated keys according to policy. Values without a
+// timestamp in the live Secret are taken as created now, so existing
+// Secrets are not rotated right away.
+func RotatePasswordSecret(
+	spec PasswordSecretSpec, existing *corev1.Secret, policy RotationPolicy,
+) (corev1.Secret, RotationResult, error) {
+	var result RotationResult = RotationResult{Created: []string{}, Rotated: []string{}, DroppedPrevious: []string{}}
+	if policy.MaxAge < 0 || policy.GracePeriod < 0 {
+		return corev1.Secret{}, result, fmt.Errorf("Secret %s/%s: max age and grace period must not be negative", spec.Namespace, spec.Name)
+	}
+	if policy.MaxAge > 0 && policy.GracePeriod >= policy.MaxAge {
+		return corev1.Secret{}, result, fmt.Errorf("Secret %s/%s: the grace period must be shorter than the max age", spec.Namespace, spec.Name)
+	}
+	var clk clock.PassiveClock = policy.Clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	var now time.Time = clk.Now().UTC().Truncate(time.Second)
+
+	secret, created, err := GeneratePasswordSecret(spec, existing)
+	if err != nil {
+		return corev1.Secret{}, result, err
+	}
+	result.Created = created
+	var timestamps map[string]rotationTimestamps = map[string]rotationTimestamps{}
+	if existing != nil && existing.Annotations[RotationAnnotation] != "" {
+		if err := json.Unmarshal([]byte(existing.Annotations[RotationAnnotation]), &timestamps); err != nil {
+			return corev1.Secret{}, result, fmt.Errorf("Secret %s/%s: invalid annotation %s: %w", spec.Namespace, spec.Name, RotationAnnotation, err)
+		}
+	}
+
+	var newTimestamps map[string]rotationTimestamps = map[string]rotationTimestamps{}
+	var createdKeys sets.Set[string] = sets.New(created...)
+	for _, key := range sets.List(sets.KeySet(spec.GeneratedKeys)) {
+		var previousKey string = key + PreviousValueKeySuffix
+		var stamps rotationTimestamps = timestamps[key]
+		if createdKeys.Has(key) || stamps.Current.IsZero() {
+			stamps = rotationTimestamps{Current: now}
+		}
+		if previous, ok := existingSecretValue(existing, previousKey); ok && stamps.Previous != nil {
+			secret.StringData[previousKey] = previous
+		} else {
+			stamps.Previous = nil
+		}
+
+		if policy.MaxAge > 0 && !now.Before(stamps.Current.Add(policy.MaxAge)) {
+			password, err := spec.GeneratedKeys[key].generate()
+			if err != nil {
+				return corev1.Secret{}, result, fmt.Errorf("Secret %s/%s: key %q: %w", spec.Namespace, spec.Name, key, err)
+			}
+			var previousCreated time.Time = stamps.Current
+			secret.StringData[previousKey] = secret.StringData[key]
+			secret.StringData[key] = password
+			stamps = rotationTimestamps{Current: now, Previous: &previousCreated}
+			result.Rotated = append(result.Rotated, key)
+		}
+		// the grace period starts with the rotation, i.e. the creation of
+		// the current value
+		if stamps.Previous != nil && !now.Before(stamps.Current.Add(policy.GracePeriod)) {
+			delete(secret.StringData, previousKey)
+			stamps.Previous = nil
+			result.DroppedPrevious = append(result.DroppedPrevious, key)
+		}
+
+		if stamps.Previous != nil {
+			result.NextChange = earliest(result.NextChange, stamps.Current.Add(policy.GracePeriod))
+		}
+		if policy.MaxAge > 0 {
+			result.NextChange = earliest(result.NextChange, stamps.Current.Add(policy.MaxAge))
+		}
+		newTimestamps[key] = stamps
+	}
+
+	// maps are encoded with sorted keys, so unchanged timestamps give an
+	// unchanged annotation
+	annotation, err := json.Marshal(newTimestamps)
+	if err != nil {
+		return corev1.Secret{}, result, err
+	}
+	secret.Annotations = map[string]string{RotationAnnotation: string(annotation)}
+	return secret, result, nil
+}
+
+func earliest(current, candidate time.Time) time.Time {
+	if current.IsZero() || candidate.Before(current) {
+		return candidate
+	}
+	return current
+}
@@ -0,0 +1,97 @@
+package negotools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// the Secret as the API server returns it after applying secret
+func liveSecret(secret corev1.Secret) *corev1.Secret {
+	var live corev1.Secret = *secret.DeepCopy()
+	live.Data = map[string][]byte{}
+	for key, value := range secret.StringData {
+		live.Data[key] = []byte(value)
+	}
+	live.StringData = nil
+	return &live
+}
+
+func TestRotatePasswordSecret(t *testing.T) {
+	var start time.Time = time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	clock := clocktesting.NewFakePassiveClock(start)
+	policy := RotationPolicy{MaxAge: 90 * 24 * time.Hour, GracePeriod: 24 * time.Hour, Clock: clock}
+	spec := PasswordSecretSpec{
+		Name: "spam-db", Namespace: "eggs",
+		Data:          map[string]string{"username": "spam"},
+		GeneratedKeys: map[string]GeneratedKeySpec{"password": {Length: 24}},
+	}
+
+	secret, result, err := RotatePasswordSecret(spec, nil, policy)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"password"}, result.Created)
+	assert.Empty(t, result.Rotated)
+	assert.Equal(t, start.Add(policy.MaxAge), result.NextChange)
+	assert.Equal(t, `{"password":{"current":"2026-10-19T08:00:00Z"}}`, secret.Annotations[RotationAnnotation])
+	var first string = secret.StringData["password"]
+
+	clock.SetTime(start.Add(policy.MaxAge - time.Second))
+	secret, result, err = RotatePasswordSecret(spec, liveSecret(secret), policy)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Rotated)
+	assert.Equal(t, first, secret.StringData["password"])
+
+	var rotatedAt time.Time = start.Add(policy.MaxAge)
+	clock.SetTime(rotatedAt)
+	secret, result, err = RotatePasswordSecret(spec, liveSecret(secret), policy)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"password"}, result.Rotated)
+	assert.NotEqual(t, first, secret.StringData["password"])
+	assert.Equal(t, first, secret.StringData["password_previous"])
+	assert.Equal(t, "spam", secret.StringData["username"])
+	assert.Equal(t, rotatedAt.Add(policy.GracePeriod), result.NextChange)
+	assert.Equal(t, `{"password":{"current":"2027-01-17T08:00:00Z","previous":"2026-10-19T08:00:00Z"}}`,
+		secret.Annotations[RotationAnnotation])
+	var second string = secret.StringData["password"]
+
+	clock.SetTime(rotatedAt.Add(policy.GracePeriod - time.Second))
+	secret, result, err = RotatePasswordSecret(spec, liveSecret(secret), policy)
+	assert.NoError(t, err)
+	assert.Empty(t, result.DroppedPrevious)
+	assert.Equal(t, first, secret.StringData["password_previous"])
+
+	clock.SetTime(rotatedAt.Add(policy.GracePeriod))
+	secret, result, err = RotatePasswordSecret(spec, liveSecret(secret), policy)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"password"}, result.DroppedPrevious)
+	assert.Equal(t, second, secret.StringData["password"])
+	assert.NotContains(t, secret.StringData, "password_previous")
+	assert.Equal(t, rotatedAt.Add(policy.MaxAge), result.NextChange)
+	assert.Equal(t, `{"password":{"current":"2027-01-17T08:00:00Z"}}`, secret.Annotations[RotationAnnotation])
+}
+
+func TestRotatePasswordSecretUntracked(t *testing.T) {
+	// Secrets created before rotation was enabled are not rotated right away
+	var now time.Time = time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	policy := RotationPolicy{MaxAge: time.Hour, Clock: clocktesting.NewFakePassiveClock(now)}
+	spec := PasswordSecretSpec{Name: "spam-db", Namespace: "eggs", GeneratedKeys: map[string]GeneratedKeySpec{"password": {Length: 24}}}
+	live := &corev1.Secret{Data: map[string][]byte{"password": []byte("ham")}}
+
+	secret, result, err := RotatePasswordSecret(spec, live, policy)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Rotated)
+	assert.Equal(t, "ham", secret.StringData["password"])
+	assert.Equal(t, now.Add(time.Hour), result.NextChange)
+
+	live.Annotations = map[string]string{RotationAnnotation: "spam"}
+	_, _, err = RotatePasswordSecret(spec, live, policy)
+	assert.ErrorContains(t, err, "invalid annotation negotools.deepshore.de/rotation")
+
+	_, _, err = RotatePasswordSecret(spec, nil, RotationPolicy{MaxAge: time.Hour, GracePeriod: time.Hour})
+	assert.EqualError(t, err, "Secret eggs/spam-db: the grace period must be shorter than the max age")
+}
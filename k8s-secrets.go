@@ -0,0 +1,86 @@
+package negotools
+
+// generators for the Secret types with a fixed layout, see ValidateSecret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DockerRegistryCredential is one entry of a kubernetes.io/dockerconfigjson
+// Secret. Registry is the server as used in image references, e.g.
+// "registry.example.com" or "https://index.docker.io/v1/" for Docker Hub.
+type DockerRegistryCredential struct {
+	Registry string
+	Username string
+	Password string
+	Email    string
+}
+
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// GenerateTLSSecret creates a kubernetes.io/tls Secret from PEM encoded
+// certificate (chain) and private key.
+func GenerateTLSSecret(name, namespaceName string, certPEM, keyPEM []byte) corev1.Secret {
+	var secret corev1.Secret = GenerateSecret(name, namespaceName, nil)
+	secret.Type = corev1.SecretTypeTLS
+	secret.Data = map[string][]byte{
+		corev1.TLSCertKey:       certPEM,
+		corev1.TLSPrivateKeyKey: keyPEM,
+	}
+	return secret
+}
+
+// GenerateDockerConfigSecret creates a kubernetes.io/dockerconfigjson
+// Secret, usable as DeploymentConfig.ImagePullSecretName. The auth field
+// of each registry is the base64 encoded "username:password".
+func GenerateDockerConfigSecret(
+	name, namespaceName string, credentials ...DockerRegistryCredential,
+) corev1.Secret {
+	var config dockerConfigJSON = dockerConfigJSON{Auths: map[string]dockerConfigEntry{}}
+	for _, credential := range credentials {
+		config.Auths[credential.Registry] = dockerConfigEntry{
+			Username: credential.Username,
+			Password: credential.Password,
+			Email:    credential.Email,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(credential.Username + ":" + credential.Password)),
+		}
+	}
+	// maps are encoded with sorted keys, so the content is stable
+	content, _ := json.Marshal(config)
+
+	var secret corev1.Secret = GenerateSecret(name, namespaceName, nil)
+	secret.Type = corev1.SecretTypeDockerConfigJson
+	secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: content}
+	return secret
+}
+
+// GenerateBasicAuthSecret creates a kubernetes.io/basic-auth Secret.
+func GenerateBasicAuthSecret(name, namespaceName, username, password string) corev1.Secret {
+	var secret corev1.Secret = GenerateSecret(name, namespaceName, map[string]string{
+		corev1.BasicAuthUsernameKey: username,
+		corev1.BasicAuthPasswordKey: password,
+	})
+	secret.Type = corev1.SecretTypeBasicAuth
+	return secret
+}
+
+// GenerateSSHAuthSecret creates a kubernetes.io/ssh-auth Secret from a PEM
+// or OpenSSH encoded private key.
+func GenerateSSHAuthSecret(name, namespaceName string, privateKey []byte) corev1.Secret {
+	var secret corev1.Secret = GenerateSecret(name, namespaceName, nil)
+	secret.Type = corev1.SecretTypeSSHAuth
+	secret.Data = map[string][]byte{corev1.SSHAuthPrivateKey: privateKey}
+	return secret
+}
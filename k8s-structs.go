@@ -6,8 +6,10 @@ import (
 	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -76,6 +78,10 @@ type DeploymentConfig struct {
 	MemoryLimitMi              int64
 	LivenessProbeSpec          ProbeSpec
 	ReadinessProbeSpec         ProbeSpec
+	// applied to every container image, see RewriteImage
+	ImageRewriteRules []ImageRewriteRule
+	// let Validate reject images that are not pinned by digest
+	RequireImageDigest bool
 }
 
 // use a struct to avoid mistakes in the order of arguments and keep things
@@ -107,14 +113,14 @@ func GenerateDeployment(config DeploymentConfig) appsv1.Deployment {
 	var cpuRequest *resource.Quantity = resource.NewMilliQuantity(config.CpuRequestMilli, resource.DecimalSI)
 	var memoryRequest *resource.Quantity = resource.NewQuantity(config.MemoryRequestMi*1024*1024, resource.BinarySI)
 	var resourceRequest corev1.ResourceList = corev1.ResourceList{
-		corev1.ResourceName("Cpu"):    *cpuRequest,
-		corev1.ResourceName("Memory"): *memoryRequest,
+		corev1.ResourceCPU:    *cpuRequest,
+		corev1.ResourceMemory: *memoryRequest,
 	}
 	var cpuLimit *resource.Quantity = resource.NewMilliQuantity(config.CpuLimitMilli, resource.DecimalSI)
 	var memoryLimit *resource.Quantity = resource.NewQuantity(config.MemoryLimitMi*1024*1024, resource.BinarySI)
 	var resourceLimit corev1.ResourceList = corev1.ResourceList{
-		corev1.ResourceName("Cpu"):    *cpuLimit,
-		corev1.ResourceName("Memory"): *memoryLimit,
+		corev1.ResourceCPU:    *cpuLimit,
+		corev1.ResourceMemory: *memoryLimit,
 	}
 	var livenessProbe corev1.Probe = corev1.Probe{
 		ProbeHandler: corev1.ProbeHandler{
@@ -163,6 +169,11 @@ func GenerateDeployment(config DeploymentConfig) appsv1.Deployment {
 			VolumeMounts:   config.VolumeMounts,
 		},
 	}
+	if len(config.ImageRewriteRules) > 0 {
+		for i := range containers {
+			containers[i].Image = rewriteContainerImage(containers[i].Image, config.ImageRewriteRules)
+		}
+	}
 	//
 	var podMeta metav1.ObjectMeta = metav1.ObjectMeta{
 		Labels: config.PodLabels,
@@ -245,3 +256,84 @@ func GenerateIngress(
 	}
 	return ingressSpec
 }
+
+func GenerateService(
+	name, namespaceName string, selector map[string]string,
+	portName string, port int32, targetPort intstr.IntOrString,
+) corev1.Service {
+
+	var servicePort corev1.ServicePort = corev1.ServicePort{
+		Name:       portName,
+		Protocol:   corev1.ProtocolTCP,
+		Port:       port,
+		TargetPort: targetPort,
+	}
+	var service corev1.Service = corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespaceName,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: selector,
+			Ports:    []corev1.ServicePort{servicePort},
+		},
+	}
+	return service
+}
+
+// scales the named Deployment by average CPU utilization (in percent of the
+// CPU request)
+func GenerateHorizontalPodAutoscaler(
+	name, namespaceName, deploymentName string,
+	minReplicas, maxReplicas, targetCpuUtilization int32,
+) autoscalingv2.HorizontalPodAutoscaler {
+
+	var cpuMetric autoscalingv2.MetricSpec = autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: corev1.ResourceCPU,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: &targetCpuUtilization,
+			},
+		},
+	}
+	var autoscaler autoscalingv2.HorizontalPodAutoscaler = autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespaceName,
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       "Deployment",
+				Name:       deploymentName,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: maxReplicas,
+			Metrics:     []autoscalingv2.MetricSpec{cpuMetric},
+		},
+	}
+	return autoscaler
+}
+
+// minAvailable may be an absolute number or a percentage ("50%")
+func GeneratePodDisruptionBudget(
+	name, namespaceName string, matchLabels map[string]string, minAvailable intstr.IntOrString,
+) policyv1.PodDisruptionBudget {
+
+	var budget policyv1.PodDisruptionBudget = policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespaceName,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: matchLabels,
+			},
+		},
+	}
+	return budget
+}
@@ -0,0 +1,25 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGenerateDeploymentResources(t *testing.T) {
+	deployment := GenerateDeployment(testDeploymentConfig())
+	var resources corev1.ResourceRequirements = deployment.Spec.Template.Spec.Containers[0].Resources
+
+	// the API server only knows the lowercase names, "Cpu" and "Memory" are
+	// rejected as extended resources without a domain
+	for _, list := range []corev1.ResourceList{resources.Requests, resources.Limits} {
+		assert.Len(t, list, 2)
+		assert.Contains(t, list, corev1.ResourceName("cpu"))
+		assert.Contains(t, list, corev1.ResourceName("memory"))
+	}
+	assert.Equal(t, "250m", resources.Requests.Cpu().String())
+	assert.Equal(t, "128Mi", resources.Requests.Memory().String())
+	assert.Equal(t, "1", resources.Limits.Cpu().String())
+	assert.Equal(t, "512Mi", resources.Limits.Memory().String())
+}
@@ -0,0 +1,467 @@
+package negotools
+
+// validation of the generator inputs, so invalid objects are found before
+// the API server rejects them
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var supportedPullPolicies sets.Set[corev1.PullPolicy] = sets.New(
+	corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever,
+)
+
+var supportedPathTypes sets.Set[networking.PathType] = sets.New(
+	networking.PathTypeExact, networking.PathTypePrefix, networking.PathTypeImplementationSpecific,
+)
+
+// Validate checks the config against the validation rules of the API server
+// and returns all problems at once as an aggregate of field errors (nil if
+// the config is valid). Field paths use the names of the config struct.
+func (config DeploymentConfig) Validate() error {
+	var errs field.ErrorList = field.ErrorList{}
+
+	errs = append(errs, validateObjectName(config.Name, config.Namespace, nil)...)
+	if config.ContainerName == "" {
+		errs = append(errs, field.Required(field.NewPath("ContainerName"), ""))
+	} else {
+		errs = append(errs, validateDNS1123Label(config.ContainerName, field.NewPath("ContainerName"))...)
+	}
+	if strings.TrimSpace(config.Image) == "" {
+		errs = append(errs, field.Required(field.NewPath("Image"), ""))
+	} else {
+		errs = append(errs, validateImage(config)...)
+	}
+	if config.ImagePullPolicy != "" && !supportedPullPolicies.Has(config.ImagePullPolicy) {
+		errs = append(errs, field.NotSupported(field.NewPath("ImagePullPolicy"), config.ImagePullPolicy, sets.List(supportedPullPolicies)))
+	}
+	if config.ImagePullSecretName != "" {
+		errs = append(errs, validateDNS1123Subdomain(config.ImagePullSecretName, field.NewPath("ImagePullSecretName"))...)
+	}
+
+	if config.PortName != "" {
+		for _, msg := range validation.IsValidPortName(config.PortName) {
+			errs = append(errs, field.Invalid(field.NewPath("PortName"), config.PortName, msg))
+		}
+	}
+	for _, msg := range validation.IsValidPortNum(int(config.ContainerPort)) {
+		errs = append(errs, field.Invalid(field.NewPath("ContainerPort"), config.ContainerPort, msg))
+	}
+	if config.Replicas < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("Replicas"), config.Replicas, "must be greater than or equal to 0"))
+	}
+
+	for _, key := range sets.List(sets.KeySet(config.EnvVarData)) {
+		for _, msg := range validation.IsEnvVarName(key) {
+			errs = append(errs, field.Invalid(field.NewPath("EnvVarData").Key(key), key, msg))
+		}
+	}
+	for i, name := range config.EnvFromConfigMapNames {
+		errs = append(errs, validateDNS1123Subdomain(name, field.NewPath("EnvFromConfigMapNames").Index(i))...)
+	}
+	for i, name := range config.EnvFromSecretNames {
+		errs = append(errs, validateDNS1123Subdomain(name, field.NewPath("EnvFromSecretNames").Index(i))...)
+	}
+
+	errs = append(errs, validateLabels(config.PodLabels, field.NewPath("PodLabels"))...)
+	errs = append(errs, validateLabels(config.MatchLabels, field.NewPath("MatchLabels"))...)
+	if len(config.MatchLabels) == 0 {
+		errs = append(errs, field.Required(field.NewPath("MatchLabels"), "the selector must not be empty"))
+	}
+	for _, key := range sets.List(sets.KeySet(config.MatchLabels)) {
+		var value string = config.MatchLabels[key]
+		if podValue, ok := config.PodLabels[key]; !ok || podValue != value {
+			errs = append(errs, field.Invalid(field.NewPath("MatchLabels").Key(key), value, "does not match PodLabels"))
+		}
+	}
+
+	errs = append(errs, validateResources(config)...)
+	errs = append(errs, validateVolumes(config)...)
+	errs = append(errs, config.LivenessProbeSpec.validate(field.NewPath("LivenessProbeSpec"))...)
+	errs = append(errs, config.ReadinessProbeSpec.validate(field.NewPath("ReadinessProbeSpec"))...)
+
+	return errs.ToAggregate()
+}
+
+func validateImage(config DeploymentConfig) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	ref, err := ParseImageReference(config.Image)
+	if err != nil {
+		return append(errs, field.Invalid(field.NewPath("Image"), config.Image, err.Error()))
+	}
+	if config.RequireImageDigest && !ref.IsPinned() {
+		errs = append(errs, field.Invalid(field.NewPath("Image"), config.Image, "must be pinned by digest"))
+	}
+	for i, rule := range config.ImageRewriteRules {
+		var path *field.Path = field.NewPath("ImageRewriteRules").Index(i)
+		if rule.Prefix == "" {
+			errs = append(errs, field.Required(path.Child("Prefix"), ""))
+		}
+		if rule.Replacement == "" {
+			errs = append(errs, field.Required(path.Child("Replacement"), ""))
+		}
+	}
+	if _, err := RewriteImage(config.Image, config.ImageRewriteRules); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("ImageRewriteRules"), config.Image, err.Error()))
+	}
+	return errs
+}
+
+func validateResources(config DeploymentConfig) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	var values map[string]int64 = map[string]int64{
+		"CpuRequestMilli": config.CpuRequestMilli,
+		"CpuLimitMilli":   config.CpuLimitMilli,
+		"MemoryRequestMi": config.MemoryRequestMi,
+		"MemoryLimitMi":   config.MemoryLimitMi,
+	}
+	for _, name := range sets.List(sets.KeySet(values)) {
+		if values[name] < 0 {
+			errs = append(errs, field.Invalid(field.NewPath(name), values[name], "must be greater than or equal to 0"))
+		}
+	}
+	if config.CpuLimitMilli < config.CpuRequestMilli {
+		errs = append(errs, field.Invalid(field.NewPath("CpuLimitMilli"), config.CpuLimitMilli,
+			"must be greater than or equal to CpuRequestMilli"))
+	}
+	if config.MemoryLimitMi < config.MemoryRequestMi {
+		errs = append(errs, field.Invalid(field.NewPath("MemoryLimitMi"), config.MemoryLimitMi,
+			"must be greater than or equal to MemoryRequestMi"))
+	}
+	return errs
+}
+
+func validateVolumes(config DeploymentConfig) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	var volumeNames sets.Set[string] = sets.New[string]()
+	for i, volume := range config.Volumes {
+		var path *field.Path = field.NewPath("Volumes").Index(i).Child("Name")
+		errs = append(errs, validateDNS1123Label(volume.Name, path)...)
+		if volumeNames.Has(volume.Name) {
+			errs = append(errs, field.Duplicate(path, volume.Name))
+		}
+		volumeNames.Insert(volume.Name)
+	}
+	for i, mount := range config.VolumeMounts {
+		var path *field.Path = field.NewPath("VolumeMounts").Index(i)
+		if !volumeNames.Has(mount.Name) {
+			errs = append(errs, field.NotFound(path.Child("Name"), mount.Name))
+		}
+		if mount.MountPath == "" {
+			errs = append(errs, field.Required(path.Child("MountPath"), ""))
+		}
+	}
+	if config.DefaultConfigMapVolumeMode < 0 || config.DefaultConfigMapVolumeMode > 0777 {
+		errs = append(errs, field.Invalid(field.NewPath("DefaultConfigMapVolumeMode"), config.DefaultConfigMapVolumeMode,
+			"must be a number between 0 and 0777 (octal)"))
+	}
+	return errs
+}
+
+func (spec ProbeSpec) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	if !strings.HasPrefix(spec.HttpGetPath, "/") {
+		errs = append(errs, field.Invalid(path.Child("HttpGetPath"), spec.HttpGetPath, "must be an absolute path"))
+	}
+	for _, msg := range validation.IsValidPortNum(int(spec.HttpGetPort)) {
+		errs = append(errs, field.Invalid(path.Child("HttpGetPort"), spec.HttpGetPort, msg))
+	}
+	var values map[string]int32 = map[string]int32{
+		"InitialDelaySeconds": spec.InitialDelaySeconds,
+		"TimeoutSeconds":      spec.TimeoutSeconds,
+		"PeriodSeconds":       spec.PeriodSeconds,
+		"FailureThreshold":    spec.FailureThreshold,
+		"SuccessThreshold":    spec.SuccessThreshold,
+	}
+	for _, name := range sets.List(sets.KeySet(values)) {
+		if values[name] < 0 {
+			errs = append(errs, field.Invalid(path.Child(name), values[name], "must be greater than or equal to 0"))
+		}
+	}
+	return errs
+}
+
+// ValidateSecretInput checks the arguments of GenerateSecret.
+func ValidateSecretInput(name, namespace string, data map[string]string) error {
+	var errs field.ErrorList = validateObjectName(name, namespace, nil)
+	errs = append(errs, validateDataKeys(data, field.NewPath("data"))...)
+	return errs.ToAggregate()
+}
+
+// ValidateSecret checks a generated Secret including the keys its type
+// requires: tls.crt and tls.key (a matching pair) for kubernetes.io/tls,
+// .dockerconfigjson with "auths" for kubernetes.io/dockerconfigjson,
+// username or password for kubernetes.io/basic-auth and ssh-privatekey for
+// kubernetes.io/ssh-auth. Keys count in data as well as in stringData.
+func ValidateSecret(secret corev1.Secret) error {
+	var errs field.ErrorList = validateObjectName(secret.Name, secret.Namespace, nil)
+	var values map[string][]byte = map[string][]byte{}
+	for key, value := range secret.Data {
+		values[key] = value
+	}
+	for key, value := range secret.StringData {
+		values[key] = []byte(value)
+	}
+	var dataPath *field.Path = field.NewPath("data")
+	for _, key := range sets.List(sets.KeySet(values)) {
+		for _, msg := range validation.IsConfigMapKey(key) {
+			errs = append(errs, field.Invalid(dataPath.Key(key), key, msg))
+		}
+	}
+	var required = func(keys ...string) {
+		for _, key := range keys {
+			if len(values[key]) == 0 {
+				errs = append(errs, field.Required(dataPath.Key(key), "required for type "+string(secret.Type)))
+			}
+		}
+	}
+
+	switch secret.Type {
+	case corev1.SecretTypeTLS:
+		required(corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+		if len(values[corev1.TLSCertKey]) > 0 && len(values[corev1.TLSPrivateKeyKey]) > 0 {
+			if _, err := tls.X509KeyPair(values[corev1.TLSCertKey], values[corev1.TLSPrivateKeyKey]); err != nil {
+				errs = append(errs, field.Invalid(dataPath.Key(corev1.TLSCertKey), "<certificate>", err.Error()))
+			}
+		}
+	case corev1.SecretTypeDockerConfigJson:
+		required(corev1.DockerConfigJsonKey)
+		if content := values[corev1.DockerConfigJsonKey]; len(content) > 0 {
+			var config dockerConfigJSON
+			if err := json.Unmarshal(content, &config); err != nil {
+				errs = append(errs, field.Invalid(dataPath.Key(corev1.DockerConfigJsonKey), "<content>", err.Error()))
+			} else if config.Auths == nil {
+				errs = append(errs, field.Required(dataPath.Key(corev1.DockerConfigJsonKey).Child("auths"), ""))
+			}
+		}
+	case corev1.SecretTypeBasicAuth:
+		if len(values[corev1.BasicAuthUsernameKey]) == 0 && len(values[corev1.BasicAuthPasswordKey]) == 0 {
+			errs = append(errs, field.Required(dataPath.Key(corev1.BasicAuthUsernameKey),
+				"username or password required for type "+string(secret.Type)))
+		}
+	case corev1.SecretTypeSSHAuth:
+		required(corev1.SSHAuthPrivateKey)
+	}
+	return errs.ToAggregate()
+}
+
+// ValidateConfigMapInput checks the arguments of GenerateConfigMap.
+func ValidateConfigMapInput(name, namespace string, data map[string]string) error {
+	var errs field.ErrorList = validateObjectName(name, namespace, nil)
+	errs = append(errs, validateDataKeys(data, field.NewPath("data"))...)
+	return errs.ToAggregate()
+}
+
+// ValidateIngressInput checks the arguments of GenerateIngress.
+func ValidateIngressInput(
+	name, namespace, dnsUri, ingressBaseUrl, serviceName, path, ingressClassName string,
+	k8sServiceName string, pathType networking.PathType,
+) error {
+	var errs field.ErrorList = validateObjectName(name, namespace, nil)
+	var host string = dnsUri + "." + ingressBaseUrl
+	for _, msg := range validation.IsDNS1123Subdomain(host) {
+		errs = append(errs, field.Invalid(field.NewPath("dnsUri"), host, msg))
+	}
+	for _, msg := range validation.IsValidPortName(serviceName) {
+		errs = append(errs, field.Invalid(field.NewPath("serviceName"), serviceName, msg))
+	}
+	for _, msg := range validation.IsDNS1035Label(k8sServiceName) {
+		errs = append(errs, field.Invalid(field.NewPath("k8sServiceName"), k8sServiceName, msg))
+	}
+	if !strings.HasPrefix(path, "/") {
+		errs = append(errs, field.Invalid(field.NewPath("path"), path, "must be an absolute path"))
+	}
+	if ingressClassName != "" {
+		errs = append(errs, validateDNS1123Subdomain(ingressClassName, field.NewPath("ingressClassName"))...)
+	}
+	if !supportedPathTypes.Has(pathType) {
+		errs = append(errs, field.NotSupported(field.NewPath("pathType"), pathType, sets.List(supportedPathTypes)))
+	}
+	return errs.ToAggregate()
+}
+
+// name and namespace of objects; the path prefix is nil for the
+// positional arguments of the generators
+func validateObjectName(name, namespace string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	if name == "" {
+		errs = append(errs, field.Required(path.Child("Name"), ""))
+	} else {
+		errs = append(errs, validateDNS1123Subdomain(name, path.Child("Name"))...)
+	}
+	if namespace != "" {
+		errs = append(errs, validateDNS1123Label(namespace, path.Child("Namespace"))...)
+	}
+	return errs
+}
+
+func validateDataKeys(data map[string]string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	for _, key := range sets.List(sets.KeySet(data)) {
+		for _, msg := range validation.IsConfigMapKey(key) {
+			errs = append(errs, field.Invalid(path.Key(key), key, msg))
+		}
+	}
+	return errs
+}
+
+func validateLabels(labels map[string]string, path *field.Path) field.ErrorList {
+	return metav1validation.ValidateLabels(labels, path)
+}
+
+func validateDNS1123Label(value string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	for _, msg := range validation.IsDNS1123Label(value) {
+		errs = append(errs, field.Invalid(path, value, msg))
+	}
+	return errs
+}
+
+func validateDNS1123Subdomain(value string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	for _, msg := range validation.IsDNS1123Subdomain(value) {
+		errs = append(errs, field.Invalid(path, value, msg))
+	}
+	return errs
+}
+
+// Validate checks an AppConfig including the Deployment input as wired by
+// GenerateApp. Field paths use the names of the config structs, e.g.
+// "Deployment.Image" or "Ingress.dnsUri".
+func (config AppConfig) Validate() error {
+	var errs field.ErrorList = validateObjectName(config.Name, config.Namespace, nil)
+	for _, msg := range validation.IsDNS1035Label(config.Name) {
+		errs = append(errs, field.Invalid(field.NewPath("Name"), config.Name, "used as Service name: "+msg))
+	}
+	errs = append(errs, validateLabels(config.Labels, field.NewPath("Labels"))...)
+	for _, msg := range validation.IsValidPortName(config.portName()) {
+		errs = append(errs, field.Invalid(field.NewPath("PortName"), config.PortName, msg))
+	}
+	if config.ServicePort != 0 {
+		for _, msg := range validation.IsValidPortNum(int(config.ServicePort)) {
+			errs = append(errs, field.Invalid(field.NewPath("ServicePort"), config.ServicePort, msg))
+		}
+	}
+	errs = append(errs, validateDataKeys(config.ConfigData, field.NewPath("ConfigData"))...)
+	errs = append(errs, validateDataKeys(config.SecretData, field.NewPath("SecretData"))...)
+	errs = append(errs, prefixFieldErrors(config.deploymentConfig().Validate(), field.NewPath("Deployment"))...)
+
+	if config.Ingress != nil {
+		errs = append(errs, prefixFieldErrors(ValidateIngressInput(config.Name, config.Namespace,
+			config.Ingress.DnsUri, config.Ingress.IngressBaseUrl, config.portName(), config.Ingress.Path,
+			config.Ingress.IngressClassName, config.Name, config.Ingress.PathType), field.NewPath("Ingress"))...)
+	}
+	if config.Autoscaling != nil {
+		var path *field.Path = field.NewPath("Autoscaling")
+		if config.Autoscaling.MinReplicas < 1 {
+			errs = append(errs, field.Invalid(path.Child("MinReplicas"), config.Autoscaling.MinReplicas, "must be greater than or equal to 1"))
+		}
+		if config.Autoscaling.MaxReplicas < config.Autoscaling.MinReplicas {
+			errs = append(errs, field.Invalid(path.Child("MaxReplicas"), config.Autoscaling.MaxReplicas, "must be greater than or equal to MinReplicas"))
+		}
+		if config.Autoscaling.TargetCpuUtilization < 1 {
+			errs = append(errs, field.Invalid(path.Child("TargetCpuUtilization"), config.Autoscaling.TargetCpuUtilization, "must be greater than or equal to 1"))
+		}
+	}
+	if config.DisruptionBudget != nil {
+		var minAvailable intstr.IntOrString = config.DisruptionBudget.MinAvailable
+		var path *field.Path = field.NewPath("DisruptionBudget", "MinAvailable")
+		if minAvailable.Type == intstr.Int && minAvailable.IntVal < 0 {
+			errs = append(errs, field.Invalid(path, minAvailable.String(), "must be greater than or equal to 0"))
+		}
+		if minAvailable.Type == intstr.String {
+			if _, err := intstr.GetScaledValueFromIntOrPercent(&minAvailable, 100, true); err != nil || !strings.HasSuffix(minAvailable.StrVal, "%") {
+				errs = append(errs, field.Invalid(path, minAvailable.String(), "must be a number or a percentage"))
+			}
+		}
+	}
+	return errs.ToAggregate()
+}
+
+// nests the field errors of an aggregate returned by another Validate
+// function below path
+func prefixFieldErrors(err error, path *field.Path) field.ErrorList {
+	var errs field.ErrorList = field.ErrorList{}
+	aggregate, ok := err.(utilerrors.Aggregate)
+	if !ok {
+		if err != nil {
+			errs = append(errs, field.InternalError(path, err))
+		}
+		return errs
+	}
+	for _, e := range aggregate.Errors() {
+		fieldErr, ok := e.(*field.Error)
+		if !ok {
+			errs = append(errs, field.InternalError(path, e))
+			continue
+		}
+		var prefixed field.Error = *fieldErr
+		prefixed.Field = path.String() + "." + fieldErr.Field
+		errs = append(errs, &prefixed)
+	}
+	return errs
+}
+
+// Validate checks a ReleaseConfig including the Deployment template with
+// both images.
+func (config ReleaseConfig) Validate() error {
+	var errs field.ErrorList = field.ErrorList{}
+	if config.Strategy != BlueGreenRelease && config.Strategy != CanaryRelease {
+		errs = append(errs, field.NotSupported(field.NewPath("Strategy"), config.Strategy,
+			[]ReleaseStrategy{BlueGreenRelease, CanaryRelease}))
+	}
+	if config.ActiveTrack != "" && config.ActiveTrack != ReleaseTrackStable && config.ActiveTrack != ReleaseTrackCandidate {
+		errs = append(errs, field.NotSupported(field.NewPath("ActiveTrack"), config.ActiveTrack,
+			[]string{ReleaseTrackStable, ReleaseTrackCandidate}))
+	}
+	if config.ActiveTrack == ReleaseTrackCandidate && config.CandidateImage == "" {
+		errs = append(errs, field.Required(field.NewPath("CandidateImage"), "the active track has no image"))
+	}
+	if config.CandidateTrafficPercent < 0 || config.CandidateTrafficPercent > 100 {
+		errs = append(errs, field.Invalid(field.NewPath("CandidateTrafficPercent"), config.CandidateTrafficPercent, "must be between 0 and 100"))
+	}
+	if config.CandidateReplicas != nil && *config.CandidateReplicas < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("CandidateReplicas"), *config.CandidateReplicas, "must be greater than or equal to 0"))
+	}
+	if config.Strategy == CanaryRelease && config.Ingress == nil {
+		errs = append(errs, field.Required(field.NewPath("Ingress"), "canary releases shift traffic with the Ingress"))
+	}
+
+	// the name is used as is for the stable Service, which has to start with
+	// a letter; the track names derived from a valid name are valid as well
+	for _, msg := range validation.IsDNS1035Label(config.Deployment.Name) {
+		errs = append(errs, field.Invalid(field.NewPath("Deployment", "Name"), config.Deployment.Name, msg))
+	}
+	var deploymentConfig DeploymentConfig = config.Deployment
+	deploymentConfig.MatchLabels = config.trackSelector(ReleaseTrackStable)
+	deploymentConfig.PodLabels = deploymentConfig.MatchLabels
+	deploymentConfig.Image = config.StableImage
+	errs = append(errs, prefixFieldErrors(deploymentConfig.Validate(), field.NewPath("Deployment"))...)
+	if config.CandidateImage != "" {
+		deploymentConfig.Image = config.CandidateImage
+		// rule errors not caused by the candidate were reported for the stable image
+		for _, err := range validateImage(deploymentConfig) {
+			if err.Field == "Image" || (err.Field == "ImageRewriteRules" && err.BadValue == config.CandidateImage) {
+				err.Field = "CandidateImage"
+				errs = append(errs, err)
+			}
+		}
+	}
+	if config.Ingress != nil {
+		errs = append(errs, prefixFieldErrors(ValidateIngressInput(config.Deployment.Name, config.Deployment.Namespace,
+			config.Ingress.DnsUri, config.Ingress.IngressBaseUrl, config.portName(), config.Ingress.Path,
+			config.Ingress.IngressClassName, config.Deployment.Name, config.Ingress.PathType), field.NewPath("Ingress"))...)
+	}
+	return errs.ToAggregate()
+}
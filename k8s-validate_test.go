@@ -0,0 +1,93 @@
+package negotools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func fieldErrorPaths(t *testing.T, err error) []string {
+	var paths []string = []string{}
+	aggregate, ok := err.(utilerrors.Aggregate)
+	if !ok {
+		t.Fatalf("expected an aggregate error, got %v", err)
+	}
+	for _, e := range aggregate.Errors() {
+		paths = append(paths, e.(*field.Error).Field)
+	}
+	return paths
+}
+
+func TestDeploymentConfigValidate(t *testing.T) {
+	assert.NoError(t, testDeploymentConfig().Validate())
+
+	config := testDeploymentConfig()
+	config.Name = "Spam_Service"
+	config.ContainerPort = 70000
+	config.EnvVarData = map[string]string{"1BAD": "x", "GOOD": "y"}
+	config.PodLabels = map[string]string{"app": "spam", "tier": "not a valid value"}
+	config.MatchLabels = map[string]string{"app": "ham"}
+	config.CpuLimitMilli = 100
+	config.LivenessProbeSpec.HttpGetPort = 0
+
+	err := config.Validate()
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		"Name",
+		"ContainerPort",
+		"EnvVarData[1BAD]",
+		"PodLabels",
+		"MatchLabels[app]",
+		"CpuLimitMilli",
+		"LivenessProbeSpec.HttpGetPort",
+	}, fieldErrorPaths(t, err))
+}
+
+func TestDeploymentConfigValidateVolumes(t *testing.T) {
+	config := testDeploymentConfig()
+	config.VolumeMounts = append(config.VolumeMounts, corev1.VolumeMount{Name: "config"})
+	err := config.Validate()
+	assert.Equal(t, []string{"VolumeMounts[0].Name", "VolumeMounts[0].MountPath"}, fieldErrorPaths(t, err))
+}
+
+func TestValidateGeneratorInputs(t *testing.T) {
+	assert.NoError(t, ValidateSecretInput("spam", "eggs", map[string]string{"password": "x"}))
+	err := ValidateSecretInput("spam", "Eggs", map[string]string{"pass word": "x"})
+	assert.Equal(t, []string{"Namespace", "data[pass word]"}, fieldErrorPaths(t, err))
+
+	assert.NoError(t, ValidateConfigMapInput("spam", "eggs", map[string]string{"app.yaml": "x"}))
+	err = ValidateConfigMapInput("", "eggs", nil)
+	assert.Equal(t, []string{"Name"}, fieldErrorPaths(t, err))
+
+	assert.NoError(t, ValidateIngressInput("spam", "eggs", "spam", "example.com", "http", "/", "nginx",
+		"spam", networking.PathTypePrefix))
+	err = ValidateIngressInput("spam", "eggs", "spam", "example.com", "http", "api", "nginx",
+		"1spam", "Regex")
+	assert.Equal(t, []string{"k8sServiceName", "path", "pathType"}, fieldErrorPaths(t, err))
+}
+
+func TestAppConfigValidate(t *testing.T) {
+	assert.NoError(t, testAppConfig().Validate())
+
+	config := testAppConfig()
+	config.Name = "1spam"
+	config.SecretData["not a key"] = "ham"
+	config.Deployment.Image = ""
+	config.Ingress.PathType = "Regex"
+	config.Autoscaling.MaxReplicas = 1
+	config.DisruptionBudget.MinAvailable = intstr.FromString("half")
+	assert.Equal(t, []string{
+		"Name",
+		"SecretData[not a key]",
+		"Deployment.Image",
+		"Ingress.k8sServiceName",
+		"Ingress.pathType",
+		"Autoscaling.MaxReplicas",
+		"DisruptionBudget.MinAvailable",
+	}, fieldErrorPaths(t, config.Validate()))
+}
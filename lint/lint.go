@@ -0,0 +1,317 @@
+// Package lint checks objects produced by the generators of ne-go-tools for
+// common mistakes before they reach a cluster.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+	// disables a rule
+	SeverityOff Severity = "off"
+)
+
+// names of the available rules
+const (
+	RuleImageTag            string = "image-tag"
+	RuleResourceLimits      string = "resource-limits"
+	RuleLimitsBelowRequests string = "limits-below-requests"
+	RuleProbes              string = "probes"
+	RuleProbePorts          string = "probe-ports"
+	RuleSelectorLabels      string = "selector-labels"
+	RuleIngressBackends     string = "ingress-backends"
+)
+
+// Config maps rule names to the severity their findings are reported with.
+// Rules missing in the map use the severity of DefaultConfig.
+type Config struct {
+	Severities map[string]Severity
+}
+
+// DefaultConfig returns the rule set used if nothing else is configured.
+func DefaultConfig() Config {
+	return Config{Severities: map[string]Severity{
+		RuleImageTag:            SeverityError,
+		RuleResourceLimits:      SeverityWarning,
+		RuleLimitsBelowRequests: SeverityError,
+		RuleProbes:              SeverityWarning,
+		RuleProbePorts:          SeverityError,
+		RuleSelectorLabels:      SeverityError,
+		RuleIngressBackends:     SeverityError,
+	}}
+}
+
+func (c Config) severity(rule string) Severity {
+	if severity, ok := c.Severities[rule]; ok {
+		return severity
+	}
+	return DefaultConfig().Severities[rule]
+}
+
+// Finding is a single problem found in an object.
+type Finding struct {
+	Rule      string
+	Severity  Severity
+	Kind      string
+	Namespace string
+	Name      string
+	// field path within the object, e.g. spec.template.spec.containers[0].image
+	Path    string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s %s/%s: %s: %s (%s)", f.Severity, f.Kind, f.Namespace, f.Name, f.Path, f.Message, f.Rule)
+}
+
+// HasErrors reports whether any of the findings has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// linter collects the findings for one Lint call
+type linter struct {
+	config   Config
+	services map[string]*corev1.Service
+	findings []Finding
+	// object currently being checked
+	kind, namespace, name string
+}
+
+func (l *linter) report(rule, path, format string, args ...interface{}) {
+	var severity Severity = l.config.severity(rule)
+	if severity == SeverityOff {
+		return
+	}
+	l.findings = append(l.findings, Finding{
+		Rule:      rule,
+		Severity:  severity,
+		Kind:      l.kind,
+		Namespace: l.namespace,
+		Name:      l.name,
+		Path:      path,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// Lint checks the given objects (Deployments, StatefulSets, DaemonSets,
+// Services, Ingresses, ...) against the rules of the config. Services in
+// the list are used to resolve Ingress backends. Findings are sorted by
+// object and path.
+func Lint(objects []runtime.Object, config Config) []Finding {
+	var l *linter = &linter{config: config, services: map[string]*corev1.Service{}, findings: []Finding{}}
+	for _, obj := range objects {
+		if service, ok := obj.(*corev1.Service); ok {
+			l.services[service.Namespace+"/"+service.Name] = service
+		}
+	}
+
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			continue
+		}
+		l.namespace, l.name = accessor.GetNamespace(), accessor.GetName()
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			l.kind = "Deployment"
+			l.checkWorkload(o.Spec.Selector, o.Spec.Template)
+		case *appsv1.StatefulSet:
+			l.kind = "StatefulSet"
+			l.checkWorkload(o.Spec.Selector, o.Spec.Template)
+		case *appsv1.DaemonSet:
+			l.kind = "DaemonSet"
+			l.checkWorkload(o.Spec.Selector, o.Spec.Template)
+		case *networking.Ingress:
+			l.kind = "Ingress"
+			l.checkIngress(o)
+		}
+	}
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		var a, b Finding = l.findings[i], l.findings[j]
+		var objectA, objectB string = a.Kind + "/" + a.Namespace + "/" + a.Name, b.Kind + "/" + b.Namespace + "/" + b.Name
+		if objectA != objectB {
+			return objectA < objectB
+		}
+		return a.Path < b.Path
+	})
+	return l.findings
+}
+
+func (l *linter) checkWorkload(selector *metav1.LabelSelector, template corev1.PodTemplateSpec) {
+	l.checkSelector(selector, template.Labels)
+	for i, container := range template.Spec.Containers {
+		var path string = fmt.Sprintf("spec.template.spec.containers[%d]", i)
+		l.checkImage(path, container.Image)
+		l.checkResources(path, container.Resources)
+		l.checkProbes(path, container)
+	}
+	for i, container := range template.Spec.InitContainers {
+		var path string = fmt.Sprintf("spec.template.spec.initContainers[%d]", i)
+		l.checkImage(path, container.Image)
+		l.checkResources(path, container.Resources)
+	}
+}
+
+func (l *linter) checkSelector(selector *metav1.LabelSelector, podLabels map[string]string) {
+	if selector == nil || (len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0) {
+		l.report(RuleSelectorLabels, "spec.selector", "selector is empty")
+		return
+	}
+	parsed, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		l.report(RuleSelectorLabels, "spec.selector", "invalid selector: %v", err)
+		return
+	}
+	if !parsed.Matches(labels.Set(podLabels)) {
+		l.report(RuleSelectorLabels, "spec.selector",
+			"selector %q does not match the pod template labels %v", parsed.String(), podLabels)
+	}
+}
+
+func (l *linter) checkImage(path, image string) {
+	path += ".image"
+	if image == "" {
+		l.report(RuleImageTag, path, "image is empty")
+		return
+	}
+	if strings.Contains(image, "@") {
+		return
+	}
+	var lastSegment string = image[strings.LastIndex(image, "/")+1:]
+	colon := strings.LastIndex(lastSegment, ":")
+	if colon < 0 {
+		l.report(RuleImageTag, path, "image %q has no tag", image)
+		return
+	}
+	if lastSegment[colon+1:] == "latest" {
+		l.report(RuleImageTag, path, "image %q uses the tag \"latest\"", image)
+	}
+}
+
+func (l *linter) checkResources(path string, resources corev1.ResourceRequirements) {
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if _, ok := resources.Limits[name]; !ok {
+			l.report(RuleResourceLimits, fmt.Sprintf("%s.resources.limits.%s", path, name), "no %s limit set", name)
+		}
+	}
+	for name, request := range resources.Requests {
+		limit, ok := resources.Limits[name]
+		if ok && limit.Cmp(request) < 0 {
+			l.report(RuleLimitsBelowRequests, fmt.Sprintf("%s.resources.limits.%s", path, name),
+				"%s limit %s is below the request %s", name, limit.String(), request.String())
+		}
+	}
+}
+
+type namedProbe struct {
+	field string
+	probe *corev1.Probe
+}
+
+func (l *linter) checkProbes(path string, container corev1.Container) {
+	var probes []namedProbe = []namedProbe{
+		{"livenessProbe", container.LivenessProbe},
+		{"readinessProbe", container.ReadinessProbe},
+		{"startupProbe", container.StartupProbe},
+	}
+	for _, p := range probes {
+		if p.probe == nil {
+			if p.field != "startupProbe" {
+				l.report(RuleProbes, path+"."+p.field, "container %q has no %s", container.Name, p.field)
+			}
+			continue
+		}
+		var port *intstr.IntOrString
+		var portPath string
+		switch {
+		case p.probe.HTTPGet != nil:
+			port, portPath = &p.probe.HTTPGet.Port, ".httpGet.port"
+		case p.probe.TCPSocket != nil:
+			port, portPath = &p.probe.TCPSocket.Port, ".tcpSocket.port"
+		case p.probe.GRPC != nil:
+			grpcPort := intstr.FromInt32(p.probe.GRPC.Port)
+			port, portPath = &grpcPort, ".grpc.port"
+		}
+		if port != nil && !containerDeclaresPort(container, *port) {
+			l.report(RuleProbePorts, path+"."+p.field+portPath,
+				"%s port %s is not declared by container %q", p.field, port.String(), container.Name)
+		}
+	}
+}
+
+func containerDeclaresPort(container corev1.Container, port intstr.IntOrString) bool {
+	for _, declared := range container.Ports {
+		if port.Type == intstr.String && declared.Name == port.StrVal {
+			return true
+		}
+		if port.Type == intstr.Int && declared.ContainerPort == port.IntVal {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *linter) checkIngress(ingress *networking.Ingress) {
+	if ingress.Spec.DefaultBackend != nil {
+		l.checkIngressBackend("spec.defaultBackend", ingress.Namespace, ingress.Spec.DefaultBackend)
+	}
+	for i, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for j, path := range rule.HTTP.Paths {
+			var backend networking.IngressBackend = path.Backend
+			l.checkIngressBackend(fmt.Sprintf("spec.rules[%d].http.paths[%d].backend", i, j), ingress.Namespace, &backend)
+		}
+	}
+}
+
+func (l *linter) checkIngressBackend(path, namespace string, backend *networking.IngressBackend) {
+	if backend.Service == nil {
+		return
+	}
+	service, ok := l.services[namespace+"/"+backend.Service.Name]
+	if !ok {
+		l.report(RuleIngressBackends, path+".service.name", "unknown Service %q", backend.Service.Name)
+		return
+	}
+	var port networking.ServiceBackendPort = backend.Service.Port
+	for _, servicePort := range service.Spec.Ports {
+		if port.Name != "" && servicePort.Name == port.Name {
+			return
+		}
+		if port.Name == "" && servicePort.Port == port.Number {
+			return
+		}
+	}
+	if port.Name != "" {
+		l.report(RuleIngressBackends, path+".service.port.name",
+			"Service %q has no port named %q", service.Name, port.Name)
+		return
+	}
+	l.report(RuleIngressBackends, path+".service.port.number",
+		"Service %q has no port %d", service.Name, port.Number)
+}
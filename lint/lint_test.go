@@ -0,0 +1,124 @@
+package lint
+
+import (
+	"testing"
+
+	negotools "github.com/deepshore/ne-go-tools"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func testDeploymentConfig() negotools.DeploymentConfig {
+	return negotools.DeploymentConfig{
+		Name:               "spam",
+		Namespace:          "eggs",
+		ContainerName:      "spam",
+		Image:              "registry.example.com/spam:1.2.3",
+		PortName:           "http",
+		ContainerPort:      8080,
+		Replicas:           1,
+		PodLabels:          map[string]string{"app": "spam"},
+		MatchLabels:        map[string]string{"app": "spam"},
+		CpuRequestMilli:    250,
+		CpuLimitMilli:      500,
+		MemoryRequestMi:    128,
+		MemoryLimitMi:      256,
+		LivenessProbeSpec:  negotools.ProbeSpec{HttpGetPath: "/healthz", HttpGetPort: 8080},
+		ReadinessProbeSpec: negotools.ProbeSpec{HttpGetPath: "/ready", HttpGetPort: 8080},
+	}
+}
+
+func testService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "spam", Namespace: "eggs"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "http", Port: 80}},
+		},
+	}
+}
+
+func rules(findings []Finding) []string {
+	var names []string = []string{}
+	for _, finding := range findings {
+		names = append(names, finding.Rule)
+	}
+	return names
+}
+
+func TestLintCleanObjects(t *testing.T) {
+	deployment := negotools.GenerateDeployment(testDeploymentConfig())
+	ingress := negotools.GenerateIngress("spam", "eggs", "spam", "example.com", "http", "/", "nginx",
+		"spam", networking.PathTypePrefix)
+	findings := Lint([]runtime.Object{&deployment, testService(), &ingress}, DefaultConfig())
+	assert.Empty(t, findings)
+	assert.False(t, HasErrors(findings))
+}
+
+func TestLintWorkload(t *testing.T) {
+	config := testDeploymentConfig()
+	config.Image = "spam"
+	config.MatchLabels = map[string]string{"app": "ham"}
+	config.CpuLimitMilli = 100
+	config.ReadinessProbeSpec.HttpGetPort = 9090
+	deployment := negotools.GenerateDeployment(config)
+	delete(deployment.Spec.Template.Spec.Containers[0].Resources.Limits, corev1.ResourceMemory)
+	deployment.Spec.Template.Spec.Containers[0].LivenessProbe = nil
+
+	findings := Lint([]runtime.Object{&deployment}, DefaultConfig())
+	assert.Equal(t, []string{
+		RuleSelectorLabels,
+		RuleImageTag,
+		RuleProbes,
+		RuleProbePorts,
+		RuleLimitsBelowRequests,
+		RuleResourceLimits,
+	}, rules(findings))
+	assert.True(t, HasErrors(findings))
+	assert.Equal(t, "spec.template.spec.containers[0].readinessProbe.httpGet.port", findings[3].Path)
+	assert.Equal(t, "Deployment", findings[0].Kind)
+	assert.Equal(t, "spam", findings[0].Name)
+}
+
+func TestLintLatestTag(t *testing.T) {
+	config := testDeploymentConfig()
+	config.Image = "localhost:5000/spam:latest"
+	deployment := negotools.GenerateDeployment(config)
+	findings := Lint([]runtime.Object{&deployment}, DefaultConfig())
+	assert.Equal(t, []string{RuleImageTag}, rules(findings))
+
+	deployment.Spec.Template.Spec.Containers[0].Image = "localhost:5000/spam@sha256:abc"
+	assert.Empty(t, Lint([]runtime.Object{&deployment}, DefaultConfig()))
+}
+
+func TestLintIngressBackends(t *testing.T) {
+	ingress := negotools.GenerateIngress("spam", "eggs", "spam", "example.com", "https", "/", "nginx",
+		"spam", networking.PathTypePrefix)
+	other := negotools.GenerateIngress("ham", "eggs", "ham", "example.com", "http", "/", "nginx",
+		"ham", networking.PathTypePrefix)
+	findings := Lint([]runtime.Object{testService(), &ingress, &other}, DefaultConfig())
+	assert.Len(t, findings, 2)
+	assert.Equal(t, "ham", findings[0].Name)
+	assert.Equal(t, `unknown Service "ham"`, findings[0].Message)
+	assert.Equal(t, "spec.rules[0].http.paths[0].backend.service.port.name", findings[1].Path)
+}
+
+func TestLintConfig(t *testing.T) {
+	config := testDeploymentConfig()
+	config.Image = "spam:latest"
+	deployment := negotools.GenerateDeployment(config)
+	deployment.Spec.Template.Spec.Containers[0].Resources.Limits = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("1"),
+	}
+
+	findings := Lint([]runtime.Object{&deployment}, Config{Severities: map[string]Severity{
+		RuleImageTag:       SeverityWarning,
+		RuleResourceLimits: SeverityOff,
+	}})
+	assert.Equal(t, []string{RuleImageTag}, rules(findings))
+	assert.Equal(t, SeverityWarning, findings[0].Severity)
+	assert.False(t, HasErrors(findings))
+}
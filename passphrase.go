@@ -0,0 +1,100 @@
+package negotools
+
+// diceware-style passphrases and random tokens with known entropy
+
+import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// the EFF short word list 2.0 (1296 words with unique three character
+// prefixes), https://www.eff.org/dice, licensed CC BY 3.0 US by the
+// Electronic Frontier Foundation
+//
+//go:embed wordlists/eff_short_wordlist_2_0.txt
+var effShortWordList string
+
+var passphraseWords func() []string = sync.OnceValue(func() []string {
+	var words []string = []string{}
+	var scanner *bufio.Scanner = bufio.NewScanner(strings.NewReader(effShortWordList))
+	for scanner.Scan() {
+		// lines are "<dice rolls>\t<word>"
+		if _, word, ok := strings.Cut(scanner.Text(), "\t"); ok {
+			words = append(words, word)
+		}
+	}
+	return words
+})
+
+// PassphraseWords returns the words passphrases are made of.
+func PassphraseWords() []string {
+	return append([]string{}, passphraseWords()...)
+}
+
+// GeneratePassphrase picks the given number of words uniformly at random
+// using crypto/rand and joins them with separator. The second return value
+// is the entropy of the passphrase in bits, about 10.3 per word.
+func GeneratePassphrase(wordCount uint, separator string) (string, float64, error) {
+	if wordCount == 0 {
+		return "", 0, errors.New("a passphrase needs at least one word")
+	}
+	var words []string = passphraseWords()
+	var picked []string = make([]string, 0, wordCount)
+	for range wordCount {
+		i, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+		if err != nil {
+			return "", 0, err
+		}
+		picked = append(picked, words[i.Int64()])
+	}
+	return strings.Join(picked, separator), float64(wordCount) * math.Log2(float64(len(words))), nil
+}
+
+// TokenEncoding is the text encoding of random tokens.
+type TokenEncoding string
+
+const (
+	// HexToken encodes with lowercase hex digits.
+	HexToken TokenEncoding = "hex"
+	// Base32Token encodes with the RFC 4648 alphabet without padding.
+	Base32Token TokenEncoding = "base32"
+	// Base64URLToken encodes with the URL-safe RFC 4648 alphabet without
+	// padding.
+	Base64URLToken TokenEncoding = "base64url"
+)
+
+// GenerateToken creates a random token with at least the requested
+// entropy in bits. The random bytes are rounded up to whole bytes, the
+// second return value is the actual entropy of the token.
+func GenerateToken(bits uint, encoding TokenEncoding) (string, float64, error) {
+	if bits == 0 {
+		return "", 0, errors.New("a token needs at least one bit of entropy")
+	}
+	var random []byte = make([]byte, (bits+7)/8)
+	var encode func([]byte) string
+	switch encoding {
+	case HexToken:
+		encode = hex.EncodeToString
+	case Base32Token:
+		encode = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString
+	case Base64URLToken:
+		encode = base64.RawURLEncoding.EncodeToString
+	default:
+		return "", 0, fmt.Errorf("unsupported token encoding %q, supported are %q, %q and %q",
+			encoding, HexToken, Base32Token, Base64URLToken)
+	}
+	if _, err := rand.Read(random); err != nil {
+		return "", 0, err
+	}
+	return encode(random), float64(len(random) * 8), nil
+}
@@ -0,0 +1,68 @@
+package negotools
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratePassphrase(t *testing.T) {
+	assert.Len(t, PassphraseWords(), 1296)
+	assert.Equal(t, "aardvark", PassphraseWords()[0])
+	assert.Equal(t, "zucchini", PassphraseWords()[1295])
+
+	passphrase, entropy, err := GeneratePassphrase(6, " ")
+	assert.NoError(t, err)
+	words := strings.Split(passphrase, " ")
+	assert.Len(t, words, 6)
+	for _, word := range words {
+		assert.Contains(t, PassphraseWords(), word)
+	}
+	assert.InDelta(t, 62.04, entropy, 0.01)
+
+	passphrase, _, err = GeneratePassphrase(1, "-")
+	assert.NoError(t, err)
+	assert.Contains(t, PassphraseWords(), passphrase)
+
+	_, _, err = GeneratePassphrase(0, "-")
+	assert.Error(t, err)
+}
+
+func TestGenerateToken(t *testing.T) {
+	token, entropy, err := GenerateToken(128, HexToken)
+	assert.NoError(t, err)
+	assert.Len(t, token, 32)
+	assert.Equal(t, 128.0, entropy)
+	decoded, err := hex.DecodeString(token)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 16)
+
+	// rounded up to whole bytes
+	token, entropy, err = GenerateToken(100, Base32Token)
+	assert.NoError(t, err)
+	assert.Equal(t, 104.0, entropy)
+	decoded, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(token)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 13)
+
+	token, entropy, err = GenerateToken(256, Base64URLToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 256.0, entropy)
+	assert.NotContains(t, token, "=")
+	decoded, err = base64.RawURLEncoding.DecodeString(token)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 32)
+
+	other, _, err := GenerateToken(256, Base64URLToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+
+	_, _, err = GenerateToken(0, HexToken)
+	assert.Error(t, err)
+	_, _, err = GenerateToken(128, "base58")
+	assert.EqualError(t, err, `unsupported token encoding "base58", supported are "hex", "base32" and "base64url"`)
+}
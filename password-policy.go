@@ -0,0 +1,174 @@
+package negotools
+
+// password generation with guaranteed character classes
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// character classes of generated passwords
+const (
+	PasswordLowercaseChars string = "abcdefghijklmnopqrstuvwxyz"
+	PasswordUppercaseChars string = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	PasswordDigitChars     string = "0123456789"
+	// DefaultPasswordSymbols are the printable ASCII symbols without space,
+	// quotes and backslash, which often break config files and shells.
+	DefaultPasswordSymbols string = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+	// URLSafePasswordSymbols are the symbols that need no escaping in URLs
+	// (RFC 3986 unreserved characters), e.g. in the user info of DSNs.
+	URLSafePasswordSymbols string = "-._~"
+)
+
+// characters that are easily confused when read or typed
+const similarPasswordChars string = "ijloIJLO01|"
+
+// PasswordPolicy describes generated passwords: the length, the minimum
+// number of characters of each class and the symbols to use ("" means no
+// symbols). Characters in ForbiddenChars are never used, ExcludeSimilarChars
+// additionally removes characters like l, I, 1, O and 0. MinEntropyBits is
+// only used by CheckPassword.
+type PasswordPolicy struct {
+	Length              uint
+	MinLowercase        uint
+	MinUppercase        uint
+	MinDigits           uint
+	MinSymbols          uint
+	Symbols             string
+	ForbiddenChars      string
+	ExcludeSimilarChars bool
+	MinEntropyBits      float64
+}
+
+// DefaultPasswordPolicy requires one character of every class and uses
+// DefaultPasswordSymbols.
+func DefaultPasswordPolicy(length uint) PasswordPolicy {
+	return PasswordPolicy{
+		Length: length, MinLowercase: 1, MinUppercase: 1, MinDigits: 1, MinSymbols: 1,
+		Symbols: DefaultPasswordSymbols,
+	}
+}
+
+// URLSafePasswordPolicy is DefaultPasswordPolicy with URLSafePasswordSymbols,
+// for passwords that end up in connection URLs and DSNs unescaped.
+func URLSafePasswordPolicy(length uint) PasswordPolicy {
+	var policy PasswordPolicy = DefaultPasswordPolicy(length)
+	policy.Symbols = URLSafePasswordSymbols
+	return policy
+}
+
+// character sets of the classes after the exclusions, in the order
+// lowercase, uppercase, digits, symbols
+func (p PasswordPolicy) classes() []string {
+	var forbidden string = p.ForbiddenChars
+	if p.ExcludeSimilarChars {
+		forbidden += similarPasswordChars
+	}
+	var remove = func(chars string) string {
+		return strings.Map(func(r rune) rune {
+			if strings.ContainsRune(forbidden, r) {
+				return -1
+			}
+			return r
+		}, chars)
+	}
+	return []string{
+		remove(PasswordLowercaseChars), remove(PasswordUppercaseChars),
+		remove(PasswordDigitChars), uniqueChars(remove(p.Symbols)),
+	}
+}
+
+func (p PasswordPolicy) minimums() []uint {
+	return []uint{p.MinLowercase, p.MinUppercase, p.MinDigits, p.MinSymbols}
+}
+
+// Validate checks that passwords can be generated for the policy.
+func (p PasswordPolicy) Validate() error {
+	var errs field.ErrorList = field.ErrorList{}
+	if p.Length == 0 {
+		errs = append(errs, field.Required(field.NewPath("Length"), ""))
+	}
+	for _, r := range p.Symbols {
+		if r > '~' || r <= ' ' || strings.ContainsRune(PasswordLowercaseChars+PasswordUppercaseChars+PasswordDigitChars, r) {
+			errs = append(errs, field.Invalid(field.NewPath("Symbols"), p.Symbols,
+				"must only contain printable ASCII characters other than letters, digits and space"))
+			break
+		}
+	}
+	var names []string = []string{"MinLowercase", "MinUppercase", "MinDigits", "MinSymbols"}
+	var classes []string = p.classes()
+	var sum, available uint = 0, 0
+	for i, min := range p.minimums() {
+		sum += min
+		if min > 0 && classes[i] == "" {
+			errs = append(errs, field.Invalid(field.NewPath(names[i]), min, "no characters of the class are left to use"))
+		}
+		available += uint(len(classes[i]))
+	}
+	if p.MinEntropyBits < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("MinEntropyBits"), p.MinEntropyBits, "must be greater than or equal to 0"))
+	}
+	if sum > p.Length {
+		errs = append(errs, field.Invalid(field.NewPath("Length"), p.Length, "must be at least the sum of the minimums"))
+	}
+	if available == 0 {
+		errs = append(errs, field.Invalid(field.NewPath("ForbiddenChars"), p.ForbiddenChars, "no characters are left to use"))
+	}
+	return errs.ToAggregate()
+}
+
+// Generate creates a password that satisfies the policy using crypto/rand.
+func (p PasswordPolicy) Generate() (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	var classes []string = p.classes()
+	var password []byte = make([]byte, 0, p.Length)
+	for i, min := range p.minimums() {
+		for range min {
+			c, err := randomChar(classes[i])
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+	}
+	var all string = strings.Join(classes, "")
+	for uint(len(password)) < p.Length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	// the required characters must not always be at the start
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i.Int64()], nil
+}
+
+func uniqueChars(chars string) string {
+	var builder strings.Builder
+	for _, r := range chars {
+		if !strings.ContainsRune(builder.String(), r) {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
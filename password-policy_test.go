@@ -0,0 +1,66 @@
+package negotools
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func countChars(password, chars string) uint {
+	var count uint = 0
+	for _, r := range password {
+		if strings.ContainsRune(chars, r) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestPasswordPolicyGenerate(t *testing.T) {
+	policy := PasswordPolicy{
+		Length: 12, MinLowercase: 2, MinUppercase: 2, MinDigits: 3, MinSymbols: 3,
+		Symbols: DefaultPasswordSymbols, ForbiddenChars: "#%", ExcludeSimilarChars: true,
+	}
+	for range 200 {
+		password, err := policy.Generate()
+		assert.NoError(t, err)
+		assert.Len(t, password, 12)
+		assert.GreaterOrEqual(t, countChars(password, PasswordLowercaseChars), uint(2), password)
+		assert.GreaterOrEqual(t, countChars(password, PasswordUppercaseChars), uint(2), password)
+		assert.GreaterOrEqual(t, countChars(password, PasswordDigitChars), uint(3), password)
+		assert.GreaterOrEqual(t, countChars(password, DefaultPasswordSymbols), uint(3), password)
+		assert.Zero(t, countChars(password, "#%ilo0O1|"), password)
+	}
+
+	// no symbols unless some are given
+	password, err := PasswordPolicy{Length: 64}.Generate()
+	assert.NoError(t, err)
+	assert.Zero(t, countChars(password, DefaultPasswordSymbols))
+}
+
+func TestURLSafePasswordPolicy(t *testing.T) {
+	for range 100 {
+		password, err := URLSafePasswordPolicy(32).Generate()
+		assert.NoError(t, err)
+		assert.Equal(t, password, url.QueryEscape(password))
+		assert.Equal(t, "postgres://spam:"+password+"@db:5432/eggs",
+			(&url.URL{Scheme: "postgres", User: url.UserPassword("spam", password), Host: "db:5432", Path: "/eggs"}).String())
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	assert.NoError(t, DefaultPasswordPolicy(4).Validate())
+
+	err := PasswordPolicy{MinDigits: 1, Symbols: "a\"b"}.Validate()
+	assert.Equal(t, []string{"Length", "Symbols", "Length"}, fieldErrorPaths(t, err))
+
+	err = PasswordPolicy{Length: 8, MinDigits: 1, ForbiddenChars: PasswordDigitChars}.Validate()
+	assert.Equal(t, []string{"MinDigits"}, fieldErrorPaths(t, err))
+
+	err = PasswordPolicy{Length: 8, ForbiddenChars: PasswordLowercaseChars + PasswordUppercaseChars + PasswordDigitChars}.Validate()
+	assert.Equal(t, []string{"ForbiddenChars"}, fieldErrorPaths(t, err))
+	_, err = PasswordPolicy{Length: 8, MinSymbols: 1}.Generate()
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package negotools
+
+// strength checks of passwords that were not generated, e.g. set by users
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// number of printable ASCII characters that are no letters or digits,
+// including space
+const asciiSymbolCount int = 33
+
+// PasswordStrength is the result of CheckPassword. EntropyBits is an
+// estimate for a password chosen at random from the character classes it
+// uses, without the characters that belong to patterns. Patterns lists the
+// repeated and sequential parts found, Reasons why the password is
+// rejected.
+type PasswordStrength struct {
+	EntropyBits float64
+	Patterns    []string
+	Reasons     []string
+}
+
+// Acceptable is true if the password satisfies the policy.
+func (s PasswordStrength) Acceptable() bool {
+	return len(s.Reasons) == 0
+}
+
+// CheckPassword checks a password against the policy: the policy's Length
+// is the minimum length, only letters, digits and the policy's Symbols
+// without ForbiddenChars are allowed and only allowed characters count
+// toward the class minimums (ExcludeSimilarChars only applies to
+// generation). Passwords with an estimated entropy below MinEntropyBits are
+// rejected, so repeated or sequential characters like "aaa", "abc", "321"
+// or "spamspam" make a password weaker but are not rejected on their own.
+func (p PasswordPolicy) CheckPassword(password string) PasswordStrength {
+	var strength PasswordStrength = PasswordStrength{Patterns: []string{}, Reasons: []string{}}
+	var runes []rune = []rune(password)
+	if len(runes) == 0 {
+		strength.Reasons = append(strength.Reasons, "must not be empty")
+		return strength
+	}
+	if uint(len(runes)) < p.Length {
+		strength.Reasons = append(strength.Reasons,
+			fmt.Sprintf("is %d characters long, at least %d are required", len(runes), p.Length))
+	}
+
+	var classes []string = []string{PasswordLowercaseChars, PasswordUppercaseChars, PasswordDigitChars, p.Symbols}
+	var names [][2]string = [][2]string{
+		{"lowercase letter", "lowercase letters"}, {"uppercase letter", "uppercase letters"},
+		{"digit", "digits"}, {"symbol", "symbols"},
+	}
+	var counts []uint = make([]uint, len(classes))
+	var notAllowed []rune = []rune{}
+	for _, r := range runes {
+		var class int = passwordCharClass(r)
+		if strings.ContainsRune(p.ForbiddenChars, r) || class < 0 || !strings.ContainsRune(classes[class], r) {
+			if !strings.ContainsRune(string(notAllowed), r) {
+				notAllowed = append(notAllowed, r)
+			}
+			// characters that are not allowed do not satisfy the minimums
+			continue
+		}
+		counts[class]++
+	}
+	for i, min := range p.minimums() {
+		if counts[i] < min {
+			var name string = names[i][1]
+			if min == 1 {
+				name = names[i][0]
+			}
+			strength.Reasons = append(strength.Reasons, fmt.Sprintf("needs at least %d %s, has %d", min, name, counts[i]))
+		}
+	}
+	if len(notAllowed) > 0 {
+		strength.Reasons = append(strength.Reasons, fmt.Sprintf("contains characters that are not allowed: %q", string(notAllowed)))
+	}
+
+	// the pool are all characters of the classes the password uses
+	var pool int = 0
+	for i, size := range []int{len(PasswordLowercaseChars), len(PasswordUppercaseChars), len(PasswordDigitChars), asciiSymbolCount} {
+		if counts[i] > 0 {
+			pool += size
+		}
+	}
+	var predictable []bool
+	predictable, strength.Patterns = passwordPatterns(runes)
+	var unpredictable int = 0
+	for _, isPredictable := range predictable {
+		if !isPredictable {
+			unpredictable++
+		}
+	}
+	if pool > 1 {
+		strength.EntropyBits = float64(unpredictable) * math.Log2(float64(pool))
+	}
+	if strength.EntropyBits < p.MinEntropyBits {
+		var reason string = fmt.Sprintf("has an estimated entropy of %.1f bits, at least %.0f are required", strength.EntropyBits, p.MinEntropyBits)
+		if len(strength.Patterns) > 0 {
+			reason += fmt.Sprintf(" (repeated and sequential characters do not count: %s)", strings.Join(strength.Patterns, ", "))
+		}
+		strength.Reasons = append(strength.Reasons, reason)
+	}
+	return strength
+}
+
+// index of the class of an ASCII character in the order of
+// PasswordPolicy.classes, -1 for other characters
+func passwordCharClass(r rune) int {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return 0
+	case r >= 'A' && r <= 'Z':
+		return 1
+	case r >= '0' && r <= '9':
+		return 2
+	case r < unicode.MaxASCII && unicode.IsPrint(r):
+		return 3
+	}
+	return -1
+}
+
+// passwordPatterns finds runs of at least three equal characters ("aaa"),
+// runs of at least three characters of the same class that ascend or
+// descend by one ("abc", "987") and parts of at least three characters
+// that are repeated right away ("spamspam"). The first character of a run
+// and the first occurrence of a repeated part stay unpredictable, the
+// other characters are marked as predictable.
+func passwordPatterns(runes []rune) ([]bool, []string) {
+	var predictable []bool = make([]bool, len(runes))
+	var patterns []string = []string{}
+
+	for start := 0; start < len(runes); {
+		var end int = start + 1
+		var step rune = 0
+		if end < len(runes) && passwordCharClass(runes[end]) == passwordCharClass(runes[start]) {
+			step = runes[end] - runes[start]
+		}
+		if step >= -1 && step <= 1 {
+			for end < len(runes) && runes[end]-runes[end-1] == step &&
+				passwordCharClass(runes[end]) == passwordCharClass(runes[start]) {
+				end++
+			}
+		}
+		if end-start >= 3 {
+			for i := start + 1; i < end; i++ {
+				predictable[i] = true
+			}
+			patterns = appendPattern(patterns, fmt.Sprintf("%q", string(runes[start:end])))
+			start = end
+		} else {
+			start++
+		}
+	}
+
+	for length := len(runes) / 2; length >= 3; length-- {
+		for start := 0; start+2*length <= len(runes); start++ {
+			if string(runes[start:start+length]) != string(runes[start+length:start+2*length]) {
+				continue
+			}
+			var alreadyPredictable bool = true
+			for i := start + length; i < start+2*length; i++ {
+				alreadyPredictable = alreadyPredictable && predictable[i]
+				predictable[i] = true
+			}
+			if !alreadyPredictable {
+				patterns = appendPattern(patterns, fmt.Sprintf("%q repeated", string(runes[start:start+length])))
+			}
+			// continue with the copy, it may be repeated again
+			start += length - 1
+		}
+	}
+	return predictable, patterns
+}
+
+func appendPattern(patterns []string, pattern string) []string {
+	for _, existing := range patterns {
+		if existing == pattern {
+			return patterns
+		}
+	}
+	return append(patterns, pattern)
+}
@@ -0,0 +1,78 @@
+package negotools
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPassword(t *testing.T) {
+	policy := DefaultPasswordPolicy(12)
+	policy.MinEntropyBits = 60
+
+	strength := policy.CheckPassword("q7#Kd9!mZ2@pLw")
+	assert.True(t, strength.Acceptable(), strength.Reasons)
+	assert.Empty(t, strength.Patterns)
+	assert.InDelta(t, 14*math.Log2(95), strength.EntropyBits, 0.001)
+
+	strength = policy.CheckPassword("spam")
+	assert.False(t, strength.Acceptable())
+	assert.Equal(t, []string{
+		"is 4 characters long, at least 12 are required",
+		"needs at least 1 uppercase letter, has 0",
+		"needs at least 1 digit, has 0",
+		"needs at least 1 symbol, has 0",
+		"has an estimated entropy of 18.8 bits, at least 60 are required",
+	}, strength.Reasons)
+
+	// the space and the quote are no allowed symbols and do not count as such
+	strength = policy.CheckPassword("correct horse\"Battery1")
+	assert.Equal(t, []string{
+		"needs at least 1 symbol, has 0",
+		`contains characters that are not allowed: " \""`,
+	}, strength.Reasons)
+	strength = policy.CheckPassword("correct-horse\"Battery1")
+	assert.Equal(t, []string{`contains characters that are not allowed: "\""`}, strength.Reasons)
+
+	policy.ForbiddenChars = "@"
+	strength = policy.CheckPassword("Pässwörd1@xyzq")
+	assert.Contains(t, strength.Reasons, `contains characters that are not allowed: "äö@"`)
+
+	assert.Equal(t, []string{"must not be empty"}, policy.CheckPassword("").Reasons)
+}
+
+func TestCheckPasswordPatterns(t *testing.T) {
+	policy := DefaultPasswordPolicy(12)
+	policy.MinEntropyBits = 60
+
+	// long enough and all classes, but mostly predictable
+	strength := policy.CheckPassword("Spam1234!!!!xyz")
+	assert.Equal(t, []string{`"1234"`, `"!!!!"`, `"xyz"`}, strength.Patterns)
+	assert.InDelta(t, 7*math.Log2(95), strength.EntropyBits, 0.001)
+	assert.Equal(t, []string{`has an estimated entropy of 46.0 bits, at least 60 are required ` +
+		`(repeated and sequential characters do not count: "1234", "!!!!", "xyz")`}, strength.Reasons)
+
+	strength = policy.CheckPassword("Sp4m!Sp4m!Sp4m!")
+	assert.Equal(t, []string{`"Sp4m!" repeated`}, strength.Patterns)
+	assert.InDelta(t, 5*math.Log2(95), strength.EntropyBits, 0.001)
+
+	assert.Equal(t, []string{`"cba"`, `"987"`}, policy.CheckPassword("cba987").Patterns)
+	// runs do not cross classes
+	assert.Empty(t, policy.CheckPassword("yzAB89").Patterns)
+
+	// patterns alone are no reason for rejection
+	policy.MinEntropyBits = 0
+	assert.True(t, policy.CheckPassword("Spam1234!!!!xyz").Acceptable())
+}
+
+func TestCheckGeneratedPassword(t *testing.T) {
+	for _, policy := range []PasswordPolicy{DefaultPasswordPolicy(16), URLSafePasswordPolicy(24)} {
+		for range 50 {
+			password, err := policy.Generate()
+			assert.NoError(t, err)
+			strength := policy.CheckPassword(password)
+			assert.True(t, strength.Acceptable(), "%s: %v", password, strength.Reasons)
+		}
+	}
+}